@@ -5,12 +5,12 @@ import (
 	"net/http"
 
 	"github.com/Raimguzhinov/dav-go/internal/auth"
+	caldavSrv "github.com/Raimguzhinov/dav-go/internal/caldav"
 	"github.com/Raimguzhinov/dav-go/internal/config"
 	mwlogger "github.com/Raimguzhinov/dav-go/internal/delivery/http/middleware/logger"
 	"github.com/Raimguzhinov/dav-go/internal/usecase"
 	"github.com/Raimguzhinov/dav-go/pkg/logger"
 	"github.com/Raimguzhinov/dav-go/pkg/postgres"
-	"github.com/ceres919/go-webdav/caldav"
 	"github.com/ceres919/go-webdav/carddav"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -49,7 +49,9 @@ func SetupRouter(log *logger.Logger, pg *postgres.Postgres, cfg *config.Config,
 	s.Use(middleware.Recoverer)
 
 	upBackend := &userPrincipalBackend{}
-	url := usecase.NewURL(cfg.PG.URL, cfg.App.CalDAVPrefix, cfg.App.CardDAVPrefix, upBackend)
+	url := usecase.NewURL(
+		cfg.PG.URL, cfg.App.CalDAVPrefix, cfg.App.CardDAVPrefix, cfg.App.CalDAVRawStorage, upBackend,
+	)
 
 	caldavBackend, carddavBackend, err := usecase.NewFromURL(url, pg, log)
 	if err != nil {
@@ -57,7 +59,7 @@ func SetupRouter(log *logger.Logger, pg *postgres.Postgres, cfg *config.Config,
 	}
 
 	carddavHandler := carddav.Handler{Backend: carddavBackend}
-	caldavHandler := caldav.Handler{Backend: caldavBackend}
+	caldavHandler := caldavSrv.NewHandler(caldavBackend)
 	handler := davHandler{
 		authBackend:    auth,
 		upBackend:      upBackend,
@@ -66,10 +68,10 @@ func SetupRouter(log *logger.Logger, pg *postgres.Postgres, cfg *config.Config,
 	}
 
 	s.Mount("/", &handler)
-	s.Mount("/.well-known/caldav", &caldavHandler)
+	s.Mount("/.well-known/caldav", caldavHandler)
 	s.Mount("/.well-known/carddav", &carddavHandler)
 	s.Mount("/{user}/"+cfg.App.CardDAVPrefix, &carddavHandler)
-	s.Mount("/{user}/"+cfg.App.CalDAVPrefix, &caldavHandler)
+	s.Mount("/{user}/"+cfg.App.CalDAVPrefix, caldavHandler)
 
 	return s
 }
@@ -0,0 +1,161 @@
+package caldav
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ceres919/go-webdav"
+)
+
+// Actions of managed attachments (RFC 8607 §3.3).
+const (
+	attachmentAddAction    = "attachment-add"
+	attachmentUpdateAction = "attachment-update"
+	attachmentRemoveAction = "attachment-remove"
+)
+
+const calManagedIDHeader = "Cal-Managed-ID"
+
+var (
+	maxAttachmentSizeName = xml.Name{Space: caldavNamespace, Local: "max-attachment-size"}
+	validManagedIDName    = xml.Name{Space: caldavNamespace, Local: "valid-managed-id-parameter"}
+	validRIDName          = xml.Name{Space: caldavNamespace, Local: "valid-rid-parameter"}
+)
+
+// ManagedAttachment is the content of a managed attachment.
+type ManagedAttachment struct {
+	MediaType string
+	Filename  string
+	Content   []byte
+}
+
+// AttachmentResult describes the calendar object resource changed by an
+// attachment action.
+type AttachmentResult struct {
+	ManagedID string
+	ETag      string
+}
+
+// AttachmentBackend is implemented by backends supporting managed attachments
+// (RFC 8607). rids restricts the change to some recurrence instances, all of
+// them are changed if rids is empty.
+type AttachmentBackend interface {
+	GetAttachment(ctx context.Context, objPath, managedID string) (*ManagedAttachment, error)
+	AddAttachment(ctx context.Context, objPath string, attachment *ManagedAttachment, rids []string) (*AttachmentResult, error)
+	UpdateAttachment(ctx context.Context, objPath, managedID string, attachment *ManagedAttachment) (*AttachmentResult, error)
+	RemoveAttachment(ctx context.Context, objPath, managedID string, rids []string) (*AttachmentResult, error)
+}
+
+func (h *Handler) serveAttachment(w http.ResponseWriter, r *http.Request) error {
+	backend, ok := h.Backend.(AttachmentBackend)
+	if !ok {
+		return webdav.NewHTTPError(http.StatusNotImplemented, nil)
+	}
+
+	attachment, err := backend.GetAttachment(r.Context(), r.URL.Path, r.URL.Query().Get("managed-id"))
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", attachment.MediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(attachment.Content)))
+	if attachment.Filename != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": attachment.Filename,
+		}))
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	_, err = w.Write(attachment.Content)
+	return err
+}
+
+func (h *Handler) serveAttachmentAction(w http.ResponseWriter, r *http.Request) error {
+	backend, ok := h.Backend.(AttachmentBackend)
+	if !ok {
+		return webdav.NewHTTPError(http.StatusNotImplemented, nil)
+	}
+
+	query := r.URL.Query()
+	var rids []string
+	if rid := query.Get("rid"); rid != "" {
+		rids = strings.Split(rid, ",")
+	}
+	managedID := query.Get("managed-id")
+
+	var res *AttachmentResult
+	var err error
+	code := http.StatusNoContent
+
+	switch query.Get("action") {
+	case attachmentAddAction:
+		attachment, err := readAttachment(r)
+		if err != nil {
+			return err
+		}
+		res, err = backend.AddAttachment(r.Context(), r.URL.Path, attachment, rids)
+		if err != nil {
+			return err
+		}
+		code = http.StatusCreated
+	case attachmentUpdateAction:
+		if managedID == "" {
+			return NewPreconditionError(http.StatusBadRequest, validManagedIDName)
+		}
+		attachment, err := readAttachment(r)
+		if err != nil {
+			return err
+		}
+		res, err = backend.UpdateAttachment(r.Context(), r.URL.Path, managedID, attachment)
+		if err != nil {
+			return err
+		}
+	case attachmentRemoveAction:
+		if managedID == "" {
+			return NewPreconditionError(http.StatusBadRequest, validManagedIDName)
+		}
+		if res, err = backend.RemoveAttachment(r.Context(), r.URL.Path, managedID, rids); err != nil {
+			return err
+		}
+	default:
+		return webdav.NewHTTPError(http.StatusBadRequest, fmt.Errorf("caldav: unsupported POST action %q", query.Get("action")))
+	}
+
+	if res.ManagedID != "" {
+		w.Header().Set(calManagedIDHeader, res.ManagedID)
+	}
+	if res.ETag != "" {
+		w.Header().Set("ETag", strconv.Quote(res.ETag))
+	}
+	w.WriteHeader(code)
+	return nil
+}
+
+// readAttachment reads the attachment sent as the body of an attachment-add
+// or attachment-update action.
+func readAttachment(r *http.Request) (*ManagedAttachment, error) {
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, webdav.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	attachment := &ManagedAttachment{
+		MediaType: "application/octet-stream",
+		Content:   content,
+	}
+	if mediaType := r.Header.Get("Content-Type"); mediaType != "" {
+		attachment.MediaType = mediaType
+	}
+	if _, params, err := mime.ParseMediaType(r.Header.Get("Content-Disposition")); err == nil {
+		attachment.Filename = params["filename"]
+	}
+	return attachment, nil
+}
@@ -2,20 +2,64 @@ package caldav
 
 import (
 	"context"
+	"time"
 
+	"github.com/Raimguzhinov/dav-go/internal/caldav/db/models"
+	"github.com/ceres919/go-webdav"
 	"github.com/ceres919/go-webdav/caldav"
 	"github.com/emersion/go-ical"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type RepositoryCaldav interface {
-	CreateCalendar(ctx context.Context, homeSetPath string, calendar *caldav.Calendar) error
-	FindCalendars(ctx context.Context) ([]caldav.Calendar, error)
-	GetCalendarObjectInfo(ctx context.Context, uid string) (*caldav.CalendarObject, error)
-	UpgradeCalendarObject(ctx context.Context,
-		uid, eventType string,
-		object *caldav.CalendarObject,
-		opts *caldav.PutCalendarObjectOptions,
-	) (*caldav.CalendarObject, error)
-	GetCalendar(ctx context.Context, uid string, propFilter []string) (*ical.Calendar, error)
-	FindCalendarObjects(ctx context.Context, folderID int, propFilter []string) ([]caldav.CalendarObject, error)
+	CreateCalendar(ctx context.Context, userID, homeSetPath string, calendar *caldav.Calendar) error
+	FindCalendars(ctx context.Context, userID string) ([]caldav.Calendar, error)
+	DeleteCalendar(ctx context.Context, userID string, folderID int) error
+	GetCalendarRevision(ctx context.Context, userID string, folderID int) (int64, error)
+	GetCalendarFolder(ctx context.Context, userID string, folderID int) (*models.Folder, error)
+	UpdateCalendar(ctx context.Context, userID string, folderID int, patch *models.FolderPatch) error
+	SyncCalendarObjects(ctx context.Context,
+		userID string,
+		folderID int,
+		revision pgtype.Int8,
+		limit int,
+	) (*models.SyncChanges, error)
+	GetCalendarObjectInfo(ctx context.Context, userID string, folderID int, name string) (*caldav.CalendarObject, error)
+	GetCalendarObjectData(ctx context.Context,
+		userID string,
+		folderID int,
+		name string,
+	) (*caldav.CalendarObject, []byte, error)
+	FindCalendarObjectByUID(ctx context.Context, userID string, folderID int, uid string) (*caldav.CalendarObject, error)
+	GetAttachment(ctx context.Context, userID string, folderID int, name, managedID string) (*models.Attachment, error)
+	UpgradeCalendarObjects(ctx context.Context, userID string, writes []models.CalendarObjectWrite) error
+	GetCalendar(ctx context.Context, folderID int, name string, propFilter []string) (*ical.Calendar, error)
+	GetCalendars(ctx context.Context, folderID int, names []string, propFilter []string) (map[string]*ical.Calendar, error)
+	FindCalendarObjects(ctx context.Context, userID string, folderID int, propFilter []string) ([]caldav.CalendarObject, error)
+	FindCalendarObjectsByName(ctx context.Context,
+		userID string,
+		folderID int,
+		names []string,
+	) ([]caldav.CalendarObject, error)
+	QueryCalendarObjects(ctx context.Context, userID string, folderID int, filter *caldav.CompFilter) ([]caldav.CalendarObject, error)
+	FindBusyPeriods(ctx context.Context,
+		userID string,
+		folderID int,
+		start, end time.Time,
+	) ([]models.BusyPeriod, []string, error)
+	FindTaskTree(ctx context.Context, userID string, folderID int) ([]*models.Task, error)
+	MoveCalendarObject(ctx context.Context,
+		userID string,
+		folderID int,
+		name string,
+		dstFolderID int,
+		dstName string,
+		ifMatch webdav.ConditionalMatch,
+	) error
+	DeleteCalendarObject(ctx context.Context,
+		userID string,
+		folderID int,
+		name string,
+		ifMatch webdav.ConditionalMatch,
+	) error
 }
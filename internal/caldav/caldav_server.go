@@ -4,18 +4,27 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"path"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Raimguzhinov/dav-go/internal/auth"
+	"github.com/Raimguzhinov/dav-go/internal/caldav/db/models"
 	"github.com/Raimguzhinov/dav-go/internal/delivery/grpc"
 	"github.com/Raimguzhinov/dav-go/internal/usecase/etag"
+	"github.com/Raimguzhinov/dav-go/pkg/postgres"
 	"github.com/ceres919/go-webdav"
 	"github.com/ceres919/go-webdav/caldav"
 	"github.com/emersion/go-ical"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type caldavServer struct {
@@ -23,17 +32,23 @@ type caldavServer struct {
 	prefix string
 	repo   RepositoryCaldav
 	gs     grpc.CalendarServer
+
+	// rawStorage keeps the iCalendar data of objects as it was put, the
+	// relational columns are then only used to answer queries.
+	rawStorage bool
 }
 
 func New(
 	upBackend webdav.UserPrincipalBackend,
 	prefix string,
 	repository RepositoryCaldav,
+	rawStorage bool,
 ) (caldav.Backend, error) {
 	s := &caldavServer{
 		UserPrincipalBackend: upBackend,
 		prefix:               prefix,
 		repo:                 repository,
+		rawStorage:           rawStorage,
 	}
 	//_ = s.createDefaultCalendar(context.Background())
 	return s, nil
@@ -48,16 +63,31 @@ func (s *caldavServer) CalendarHomeSetPath(ctx context.Context) (string, error)
 	return path.Join(upPath, s.prefix) + "/", nil
 }
 
+func (s *caldavServer) currentUser(ctx context.Context) (string, error) {
+	authCtx, ok := auth.FromContext(ctx)
+	if !ok || authCtx == nil {
+		return "", webdav.NewHTTPError(
+			http.StatusUnauthorized,
+			fmt.Errorf("unauthenticated requests are not supported"),
+		)
+	}
+	return authCtx.UserName, nil
+}
+
 func (s *caldavServer) CreateCalendar(ctx context.Context, calendar *caldav.Calendar) error {
 	homeSetPath, err := s.CalendarHomeSetPath(ctx)
 	if err != nil {
 		return err
 	}
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return err
+	}
 	if calendar.MaxResourceSize == 0 || calendar.SupportedComponentSet == nil {
 		return s.createDefaultCalendar(ctx, calendar.Name)
 	}
-	if err := s.repo.CreateCalendar(ctx, homeSetPath, calendar); err != nil {
-		return err
+	if err := s.repo.CreateCalendar(ctx, userID, homeSetPath, calendar); err != nil {
+		return postgres.HTTPError(err)
 	}
 	return nil
 }
@@ -75,11 +105,16 @@ func (s *caldavServer) createDefaultCalendar(ctx context.Context, name string) e
 }
 
 func (s *caldavServer) ListCalendars(ctx context.Context) ([]caldav.Calendar, error) {
-	cals, err := s.repo.FindCalendars(ctx)
+	userID, err := s.currentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	cals, err := s.repo.FindCalendars(ctx, userID)
+	if err != nil {
+		return nil, postgres.HTTPError(err)
+	}
+
 	for i, cal := range cals {
 		homeSetPath, err := s.CalendarHomeSetPath(ctx)
 		if err != nil {
@@ -91,11 +126,16 @@ func (s *caldavServer) ListCalendars(ctx context.Context) ([]caldav.Calendar, er
 }
 
 func (s *caldavServer) GetCalendar(ctx context.Context, urlPath string) (*caldav.Calendar, error) {
-	cals, err := s.repo.FindCalendars(ctx)
+	userID, err := s.currentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	cals, err := s.repo.FindCalendars(ctx, userID)
+	if err != nil {
+		return nil, postgres.HTTPError(err)
+	}
+
 	for _, cal := range cals {
 		homeSetPath, err := s.CalendarHomeSetPath(ctx)
 		if err != nil {
@@ -105,7 +145,7 @@ func (s *caldavServer) GetCalendar(ctx context.Context, urlPath string) (*caldav
 			return &cal, nil
 		}
 	}
-	return nil, fmt.Errorf("calendar for path: %s not found", urlPath)
+	return nil, webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("calendar for path: %s not found", urlPath))
 }
 
 func (s *caldavServer) GetCalendarObject(
@@ -117,46 +157,59 @@ func (s *caldavServer) GetCalendarObject(
 	if req != nil && !req.AllProps {
 		propFilter = req.Props
 	}
-	uid := strings.TrimSuffix(path.Base(objPath), ".ics")
-	if err := uuid.Validate(uid); err != nil {
-		return nil, fmt.Errorf("object for path: %s not found", objPath)
-	}
-
-	cal, err := s.repo.GetCalendar(ctx, uid, propFilter)
+	userID, err := s.currentUser(ctx)
 	if err != nil {
 		return nil, err
 	}
-	obj, err := s.repo.GetCalendarObjectInfo(ctx, uid)
+	folderID, name, err := s.objectName(ctx, objPath)
 	if err != nil {
-		return nil, fmt.Errorf("object for path: %s not found", objPath)
+		return nil, err
 	}
 
-	var buf bytes.Buffer
-	f := bufio.NewWriter(&buf)
-
-	enc := ical.NewEncoder(f)
-	err = enc.Encode(cal)
+	obj, data, err := s.repo.GetCalendarObjectData(ctx, userID, folderID, name)
 	if err != nil {
-		return nil, err
+		return nil, postgres.HTTPError(err)
 	}
-
-	err = f.Flush()
+	cal, err := s.calendarData(ctx, folderID, name, objPath, propFilter)
 	if err != nil {
 		return nil, err
 	}
 
-	length := int64(buf.Len())
-
 	obj.Path = objPath
-	obj.Data = cal
-
-	if obj.ContentLength != length {
-		obj.ContentLength = length
+	obj.Data = applyCalendarData(ctx, cal)
+	if data == nil {
+		// The object is encoded anew from its columns, its length is only
+		// known once it is
+		obj.ContentLength = 0
 	}
 
 	return obj, nil
 }
 
+// GetCalendarObjectData returns the object along with the iCalendar data it was
+// put with. Paths which are not objects and objects stored by their columns
+// only get nil data, caldav.Handler serves them.
+func (s *caldavServer) GetCalendarObjectData(ctx context.Context, objPath string) (*caldav.CalendarObject, []byte, error) {
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	folderID, name, err := s.objectName(ctx, objPath)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	obj, data, err := s.repo.GetCalendarObjectData(ctx, userID, folderID, name)
+	switch {
+	case errors.Is(err, postgres.ErrNotFound):
+		return nil, nil, nil
+	case err != nil:
+		return nil, nil, postgres.HTTPError(err)
+	}
+	obj.Path = objPath
+	return obj, data, nil
+}
+
 func (s *caldavServer) ListCalendarObjects(
 	ctx context.Context,
 	urlPath string,
@@ -167,28 +220,20 @@ func (s *caldavServer) ListCalendarObjects(
 		propFilter = req.Props
 	}
 
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
 	homeSetPath, _ := s.CalendarHomeSetPath(ctx)
 	folderID, err := strconv.Atoi(path.Base(urlPath))
 	if err != nil {
 		return nil, fmt.Errorf("invalid folder_id: %s", urlPath)
 	}
-	objs, err := s.repo.FindCalendarObjects(ctx, folderID, propFilter)
+	objs, err := s.repo.FindCalendarObjects(ctx, userID, folderID, propFilter)
 	if err != nil {
-		return nil, err
+		return nil, postgres.HTTPError(err)
 	}
-
-	for i, obj := range objs {
-		uid := strings.TrimSuffix(path.Base(obj.Path), ".ics")
-
-		cal, err := s.repo.GetCalendar(ctx, uid, propFilter)
-		if err != nil {
-			return nil, err
-		}
-
-		objs[i].Data = cal
-		objs[i].Path = path.Join(homeSetPath, strconv.Itoa(folderID), uid+".ics")
-	}
-	return objs, nil
+	return s.calendarsData(ctx, homeSetPath, folderID, objs, propFilter)
 }
 
 func (s *caldavServer) QueryCalendarObjects(
@@ -201,28 +246,191 @@ func (s *caldavServer) QueryCalendarObjects(
 		propFilter = query.CompRequest.Props
 	}
 
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
 	homeSetPath, _ := s.CalendarHomeSetPath(ctx)
 	folderID, err := strconv.Atoi(path.Base(urlPath))
 	if err != nil {
 		return nil, fmt.Errorf("invalid folder_id: %s", urlPath)
 	}
-	objs, err := s.repo.FindCalendarObjects(ctx, folderID, propFilter)
+	var compFilter *caldav.CompFilter
+	if query != nil {
+		compFilter = &query.CompFilter
+	}
+	objs, err := s.repo.QueryCalendarObjects(ctx, userID, folderID, compFilter)
+	if err != nil {
+		return nil, postgres.HTTPError(err)
+	}
+	objs, err = s.calendarsData(ctx, homeSetPath, folderID, objs, propFilter)
 	if err != nil {
 		return nil, err
 	}
 
-	for i, obj := range objs {
-		uid := strings.TrimSuffix(path.Base(obj.Path), ".ics")
+	query, objs = filterTimeRanges(query, objs)
+	objs, err = caldav.Filter(query, objs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range objs {
+		objs[i].Data = applyCalendarData(ctx, objs[i].Data)
+	}
+	return objs, nil
+}
 
-		cal, err := s.repo.GetCalendar(ctx, uid, propFilter)
+// MultiGetCalendarObjects returns the objects at the paths, loading those of
+// a calendar at once. Paths of missing objects are left out.
+func (s *caldavServer) MultiGetCalendarObjects(ctx context.Context, paths []string) ([]caldav.CalendarObject, error) {
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	homeSetPath, err := s.CalendarHomeSetPath(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var folderIDs []int
+	names := make(map[int][]string)
+	for _, p := range paths {
+		folderID, name, err := s.objectName(ctx, p)
+		if err != nil {
+			continue
+		}
+		if _, ok := names[folderID]; !ok {
+			folderIDs = append(folderIDs, folderID)
+		}
+		names[folderID] = append(names[folderID], name)
+	}
+
+	var result []caldav.CalendarObject
+	for _, folderID := range folderIDs {
+		objs, err := s.repo.FindCalendarObjectsByName(ctx, userID, folderID, names[folderID])
+		if err != nil {
+			return nil, postgres.HTTPError(err)
+		}
+		objs, err = s.calendarsData(ctx, homeSetPath, folderID, objs, nil)
 		if err != nil {
 			return nil, err
 		}
+		result = append(result, objs...)
+	}
+	for i := range result {
+		result[i].Data = applyCalendarData(ctx, result[i].Data)
+	}
+	return result, nil
+}
+
+// filterTimeRanges applies the time ranges of VEVENT and VJOURNAL
+// comp-filters and returns the query left to caldav.Filter, which supports
+// neither VJOURNAL nor RDATE and overridden instances.
+func filterTimeRanges(
+	query *caldav.CalendarQuery,
+	objs []caldav.CalendarObject,
+) (*caldav.CalendarQuery, []caldav.CalendarObject) {
+	if query == nil {
+		return nil, objs
+	}
+
+	q := *query
+	q.CompFilter.Comps = append([]caldav.CompFilter(nil), query.CompFilter.Comps...)
+	for i, cf := range q.CompFilter.Comps {
+		if cf.Name != ical.CompEvent && cf.Name != ical.CompJournal || cf.IsNotDefined || cf.Start.IsZero() {
+			continue
+		}
+		var matched []caldav.CalendarObject
+		for _, obj := range objs {
+			if models.MatchTimeRange(obj.Data, cf.Name, cf.Start, cf.End) {
+				matched = append(matched, obj)
+			}
+		}
+		objs = matched
+		q.CompFilter.Comps[i].Start, q.CompFilter.Comps[i].End = time.Time{}, time.Time{}
+	}
+	return &q, objs
+}
+
+// calendarData returns the calendar of the object with managed attachments
+// pointing to the server.
+func (s *caldavServer) calendarData(
+	ctx context.Context,
+	folderID int,
+	name, objPath string,
+	propFilter []string,
+) (*ical.Calendar, error) {
+	cal, err := s.repo.GetCalendar(ctx, folderID, name, propFilter)
+	if err != nil {
+		return nil, postgres.HTTPError(err)
+	}
+	setAttachmentURLs(ctx, cal, objPath)
+	return cal, nil
+}
+
+// calendarsData loads the calendars of the folder objects, named by their
+// paths, at once. Paths are made absolute and objects removed meanwhile are
+// left out.
+func (s *caldavServer) calendarsData(
+	ctx context.Context,
+	homeSetPath string,
+	folderID int,
+	objs []caldav.CalendarObject,
+	propFilter []string,
+) ([]caldav.CalendarObject, error) {
+	if len(objs) == 0 {
+		return objs, nil
+	}
+	names := make([]string, len(objs))
+	for i, obj := range objs {
+		names[i] = obj.Path
+	}
+	cals, err := s.repo.GetCalendars(ctx, folderID, names, propFilter)
+	if err != nil {
+		return nil, postgres.HTTPError(err)
+	}
+
+	result := objs[:0]
+	for _, obj := range objs {
+		cal, ok := cals[obj.Path]
+		if !ok {
+			continue
+		}
+		obj.Path = path.Join(homeSetPath, strconv.Itoa(folderID), obj.Path)
+		setAttachmentURLs(ctx, cal, obj.Path)
+		obj.Data = cal
+		result = append(result, obj)
+	}
+	return result, nil
+}
+
+func setAttachmentURLs(ctx context.Context, cal *ical.Calendar, objPath string) {
+	for _, child := range cal.Children {
+		attachments := child.Props.Values(ical.PropAttach)
+		for i := range attachments {
+			managedID := attachments[i].Params.Get(models.ParamManagedID)
+			if managedID != "" && attachments[i].ValueType() != ical.ValueBinary {
+				attachments[i].Value = attachmentURL(ctx, objPath, managedID)
+			}
+		}
+	}
+}
 
-		objs[i].Data = cal
-		objs[i].Path = path.Join(homeSetPath, strconv.Itoa(folderID), uid+".ics")
+func attachmentURL(ctx context.Context, objPath, managedID string) string {
+	u := url.URL{Path: objPath, RawQuery: url.Values{"managed-id": {managedID}}.Encode()}
+	return originFromContext(ctx) + u.String()
+}
+
+// applyCalendarData applies the expand and limit-recurrence-set modifiers of
+// the current REPORT request to the calendar.
+func applyCalendarData(ctx context.Context, cal *ical.Calendar) *ical.Calendar {
+	data := calendarDataFromContext(ctx)
+	switch {
+	case data.Expand != nil:
+		return models.ExpandCalendar(cal, data.Expand.Start, data.Expand.End)
+	case data.LimitRecurrenceSet != nil:
+		return models.LimitRecurrenceSet(cal, data.LimitRecurrenceSet.Start, data.LimitRecurrenceSet.End)
 	}
-	return caldav.Filter(query, objs)
+	return cal
 }
 
 func (s *caldavServer) PutCalendarObject(
@@ -231,45 +439,120 @@ func (s *caldavServer) PutCalendarObject(
 	calendar *ical.Calendar,
 	opts *caldav.PutCalendarObjectOptions,
 ) (*caldav.CalendarObject, error) {
+	return s.putCalendarObject(ctx, objPath, calendar, opts, false, nil)
+}
+
+// putCalendarObject stores the calendar under objPath. With replace, an object
+// stored there holding another UID is replaced rather than a conflict. The
+// calendar is only stored if source, when given, is still at its version.
+func (s *caldavServer) putCalendarObject(
+	ctx context.Context,
+	objPath string,
+	calendar *ical.Calendar,
+	opts *caldav.PutCalendarObjectOptions,
+	replace bool,
+	source *models.ObjectVersion,
+) (*caldav.CalendarObject, error) {
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	folderID, name, err := s.objectName(ctx, objPath)
+	if err != nil {
+		return nil, err
+	}
 	eventType, uid, err := caldav.ValidateCalendarObject(calendar)
 	if err != nil {
 		return nil, caldav.NewPreconditionError(caldav.PreconditionValidCalendarObjectResource)
 	}
-	// Object always get saved as <UID>.ics
+	if !models.ValidRecurrences(calendar) {
+		return nil, NewPreconditionError(http.StatusForbidden, validCalendarDataName)
+	}
+
 	dirname, _ := path.Split(objPath)
-	objPath = path.Join(dirname, uid+".ics")
-
-	var tzIndex int
-	shouldRemoveTimezone := false
-
-	for i, child := range calendar.Component.Children {
-		if child.Name == ical.CompTimezone {
-			tzIndex = i
-			shouldRemoveTimezone = true
-		}
-		if child.Name == eventType {
-			for _, v := range child.Props {
-				if v[0].ValueType() == ical.ValueDateTime {
-					oldTime, _ := v[0].DateTime(time.UTC)
-					v[0].SetDateTime(oldTime.UTC())
-					delete(v[0].Params, ical.ParamTimezoneID)
-				}
-			}
+	cal, err := s.GetCalendar(ctx, dirname)
+	if err != nil {
+		return nil, webdav.NewHTTPError(http.StatusNotFound, err)
+	}
+	if !slices.Contains(cal.SupportedComponentSet, eventType) {
+		return nil, NewPreconditionError(http.StatusForbidden, supportedCalendarComponentName)
+	}
+	if replace {
+		_, err = s.checkUIDHolder(ctx, userID, folderID, name, uid)
+	} else {
+		err = s.checkUIDConflict(ctx, userID, folderID, name, uid)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// RANGE=THISANDFUTURE overrides end the series, the new ones are stored
+	// along with the object or not at all
+	calendar, series := models.SplitThisAndFuture(calendar)
+
+	writes := make([]models.CalendarObjectWrite, 0, 1+len(series))
+	// The data as put is only kept when it is stored unchanged
+	var raw []byte
+	if len(series) == 0 {
+		raw = calendarBodyFromContext(ctx)
+	}
+	w, err := s.newCalendarObjectWrite(objPath, uid, eventType, calendar, raw, cal.MaxResourceSize, opts)
+	if err != nil {
+		return nil, err
+	}
+	w.Replace = replace
+	w.Source = source
+	writes = append(writes, *w)
+
+	for _, next := range series {
+		_, nextUID, err := caldav.ValidateCalendarObject(next)
+		if err != nil {
+			return nil, caldav.NewPreconditionError(caldav.PreconditionValidCalendarObjectResource)
 		}
+		// The UID of a series depends on the split only, putting the object
+		// again with its override updates the series split off before
+		nextName := seriesName(nextUID)
+		if err := s.checkUIDConflict(ctx, userID, folderID, nextName, nextUID); err != nil {
+			return nil, err
+		}
+		w, err := s.newCalendarObjectWrite(path.Join(dirname, nextName), nextUID, eventType, next, nil,
+			cal.MaxResourceSize, &caldav.PutCalendarObjectOptions{})
+		if err != nil {
+			return nil, err
+		}
+		w.ThisAndFuture = true
+		writes = append(writes, *w)
+		writes[0].Series = append(writes[0].Series, nextName)
 	}
 
-	if shouldRemoveTimezone {
-		calendar.Component.Children = append(
-			calendar.Component.Children[:tzIndex],
-			calendar.Component.Children[tzIndex+1:]...,
-		)
+	if err := s.repo.UpgradeCalendarObjects(ctx, userID, writes); err != nil {
+		return nil, postgres.HTTPError(err)
 	}
 
+	obj := *writes[0].Object
+	if len(series) > 0 {
+		// The object is not stored as it was put, the client has to fetch it
+		// (RFC 4791 §5.3.4)
+		obj.ETag = ""
+	}
+	return &obj, nil
+}
+
+// newCalendarObjectWrite encodes the calendar to be stored under objPath,
+// checking its size against the limit of the calendar if any. raw is the data
+// the calendar was put with, if it was put by the client as it is.
+func (s *caldavServer) newCalendarObjectWrite(
+	objPath, uid, eventType string,
+	calendar *ical.Calendar,
+	raw []byte,
+	maxResourceSize int64,
+	opts *caldav.PutCalendarObjectOptions,
+) (*models.CalendarObjectWrite, error) {
 	var buf bytes.Buffer
 	f := bufio.NewWriter(&buf)
 
 	enc := ical.NewEncoder(f)
-	err = enc.Encode(calendar)
+	err := enc.Encode(calendar)
 	if err != nil {
 		return nil, err
 	}
@@ -279,26 +562,699 @@ func (s *caldavServer) PutCalendarObject(
 		return nil, err
 	}
 
-	eTag, err := etag.FromData(buf.Bytes())
+	size := int64(buf.Len()) - managedAttachmentsSize(calendar)
+	if maxResourceSize > 0 && size > maxResourceSize {
+		return nil, NewPreconditionError(http.StatusForbidden, maxResourceSizeName)
+	}
+
+	// The stored data is served as it is, so the ETag is the one of exactly
+	// these bytes. ATTACH values of managed attachments are filled in per
+	// request, objects holding them are served from the columns
+	var data []byte
+	body := buf.Bytes()
+	if s.rawStorage && !models.HasManagedAttachments(calendar) {
+		data = raw
+		if data == nil {
+			data = body
+		}
+		body = data
+	}
+
+	eTag, err := etag.FromData(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CalendarObjectWrite{
+		UID:       uid,
+		EventType: eventType,
+		Object: &caldav.CalendarObject{
+			Path:          objPath,
+			ContentLength: int64(len(body)),
+			Data:          calendar,
+			ETag:          eTag,
+			ModTime:       time.Now().UTC(),
+		},
+		Data: data,
+		Opts: opts,
+	}, nil
+}
+
+// seriesName returns the resource name of a series split off an object, named
+// after its UID. A slash can't be part of a resource name.
+func seriesName(uid string) string {
+	return strings.ReplaceAll(uid, "/", "_") + ".ics"
+}
+
+// checkUIDConflict ensures that no other object of the folder holds the UID,
+// and that the object stored under the name, if any, holds the same one.
+func (s *caldavServer) checkUIDConflict(ctx context.Context, userID string, folderID int, name, uid string) error {
+	held, err := s.checkUIDHolder(ctx, userID, folderID, name, uid)
+	if err != nil || held {
+		return err
+	}
+
+	obj, err := s.repo.GetCalendarObjectInfo(ctx, userID, folderID, name)
+	switch {
+	case err == nil:
+		homeSetPath, err := s.CalendarHomeSetPath(ctx)
+		if err != nil {
+			return err
+		}
+		return newUIDConflictError(path.Join(homeSetPath, obj.Path))
+	case !errors.Is(err, postgres.ErrNotFound):
+		return postgres.HTTPError(err)
+	}
+	return nil
+}
+
+// checkUIDHolder ensures that no object of the folder but the one stored under
+// the name holds the UID, and reports whether that one does.
+func (s *caldavServer) checkUIDHolder(ctx context.Context, userID string, folderID int, name, uid string) (bool, error) {
+	obj, err := s.repo.FindCalendarObjectByUID(ctx, userID, folderID, uid)
+	switch {
+	case err == nil && path.Base(obj.Path) == name:
+		return true, nil
+	case err == nil:
+		homeSetPath, err := s.CalendarHomeSetPath(ctx)
+		if err != nil {
+			return false, err
+		}
+		return false, newUIDConflictError(path.Join(homeSetPath, obj.Path))
+	case !errors.Is(err, postgres.ErrNotFound):
+		return false, postgres.HTTPError(err)
+	}
+	return false, nil
+}
+
+func newUIDConflictError(href string) error {
+	return &PreconditionError{Code: http.StatusForbidden, Condition: noUIDConflictName, Href: href}
+}
+
+// objectName returns the folder and the resource name of a calendar object.
+func (s *caldavServer) objectName(ctx context.Context, objPath string) (int, string, error) {
+	dir, name := path.Split(objPath)
+	folderID, ok := s.collectionFolderID(ctx, dir)
+	if !ok || name == "" {
+		return 0, "", webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("object for path: %s not found", objPath))
+	}
+	return folderID, name, nil
+}
+
+func (s *caldavServer) GetAttachment(ctx context.Context, objPath, managedID string) (*ManagedAttachment, error) {
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	folderID, name, err := s.objectName(ctx, objPath)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment, err := s.repo.GetAttachment(ctx, userID, folderID, name, managedID)
 	if err != nil {
+		return nil, postgres.HTTPError(err)
+	}
+	return &ManagedAttachment{
+		MediaType: attachment.MediaType.String,
+		Filename:  attachment.Filename.String,
+		Content:   attachment.Content,
+	}, nil
+}
+
+func (s *caldavServer) AddAttachment(
+	ctx context.Context,
+	objPath string,
+	attachment *ManagedAttachment,
+	rids []string,
+) (*AttachmentResult, error) {
+	if err := s.checkAttachmentSize(ctx, objPath, attachment); err != nil {
 		return nil, err
 	}
 
-	obj := &caldav.CalendarObject{
-		Path:          objPath,
-		ContentLength: int64(buf.Len()),
-		Data:          calendar,
-		ETag:          eTag,
-		ModTime:       time.Now().UTC(),
+	managedID := uuid.NewString()
+	prop := newManagedAttachment(managedID, attachment)
+	res, err := s.updateAttachments(ctx, objPath, func(cal *ical.Calendar) error {
+		instances, ok := models.FindInstances(cal, rids)
+		if !ok {
+			return NewPreconditionError(http.StatusForbidden, validRIDName)
+		}
+		for _, comp := range instances {
+			comp.Props.Add(prop)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return s.repo.UpgradeCalendarObject(ctx, uid, eventType, obj, opts)
+	res.ManagedID = managedID
+	return res, nil
 }
 
-func (s *caldavServer) DeleteCalendarObject(ctx context.Context, path string) error {
-	//delete(s.objectMap, path)
+func (s *caldavServer) UpdateAttachment(
+	ctx context.Context,
+	objPath, managedID string,
+	attachment *ManagedAttachment,
+) (*AttachmentResult, error) {
+	if err := s.checkAttachmentSize(ctx, objPath, attachment); err != nil {
+		return nil, err
+	}
+
+	// The MANAGED-ID changes together with the content (RFC 8607 §3.5)
+	newManagedID := uuid.NewString()
+	prop := newManagedAttachment(newManagedID, attachment)
+	res, err := s.updateAttachments(ctx, objPath, func(cal *ical.Calendar) error {
+		found := false
+		for _, comp := range cal.Children {
+			if models.ReplaceManagedAttachment(comp, managedID, prop) {
+				found = true
+			}
+		}
+		if !found {
+			return NewPreconditionError(http.StatusForbidden, validManagedIDName)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res.ManagedID = newManagedID
+	return res, nil
+}
+
+func (s *caldavServer) RemoveAttachment(
+	ctx context.Context,
+	objPath, managedID string,
+	rids []string,
+) (*AttachmentResult, error) {
+	return s.updateAttachments(ctx, objPath, func(cal *ical.Calendar) error {
+		instances, ok := models.FindInstances(cal, rids)
+		if !ok {
+			return NewPreconditionError(http.StatusForbidden, validRIDName)
+		}
+		found := false
+		for _, comp := range instances {
+			if models.ReplaceManagedAttachment(comp, managedID, nil) {
+				found = true
+			}
+		}
+		if !found {
+			return NewPreconditionError(http.StatusForbidden, validManagedIDName)
+		}
+		return nil
+	})
+}
+
+// updateAttachments applies the change to the calendar object and stores it
+// as a PUT would, so that its ETag and change log follow.
+func (s *caldavServer) updateAttachments(
+	ctx context.Context,
+	objPath string,
+	update func(cal *ical.Calendar) error,
+) (*AttachmentResult, error) {
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	folderID, name, err := s.objectName(ctx, objPath)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.repo.GetCalendarObjectInfo(ctx, userID, folderID, name)
+	if err != nil {
+		return nil, webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("object for path: %s not found", objPath))
+	}
+	cal, err := s.calendarData(ctx, folderID, name, objPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := update(cal); err != nil {
+		return nil, err
+	}
+
+	ifMatch := conditionsFromContext(ctx).IfMatch
+	if !ifMatch.IsSet() {
+		ifMatch = webdav.ConditionalMatch(strconv.Quote(info.ETag))
+	}
+	obj, err := s.PutCalendarObject(ctx, objPath, cal, &caldav.PutCalendarObjectOptions{IfMatch: ifMatch})
+	if err != nil {
+		return nil, err
+	}
+	return &AttachmentResult{ETag: obj.ETag}, nil
+}
+
+// checkAttachmentSize keeps managed attachments within the max_size of the
+// calendar.
+func (s *caldavServer) checkAttachmentSize(ctx context.Context, objPath string, attachment *ManagedAttachment) error {
+	cal, err := s.GetCalendar(ctx, path.Dir(objPath)+"/")
+	if err != nil {
+		return webdav.NewHTTPError(http.StatusNotFound, err)
+	}
+	if cal.MaxResourceSize > 0 && int64(len(attachment.Content)) > cal.MaxResourceSize {
+		return NewPreconditionError(http.StatusRequestEntityTooLarge, maxAttachmentSizeName)
+	}
 	return nil
 }
 
+// managedAttachmentsSize returns the length of the inline content of managed
+// attachments, which is limited by max-attachment-size rather than by the
+// max_size of the calendar.
+func managedAttachmentsSize(cal *ical.Calendar) int64 {
+	var size int64
+	for _, child := range cal.Children {
+		for _, prop := range child.Props.Values(ical.PropAttach) {
+			if prop.Params.Get(models.ParamManagedID) != "" && prop.ValueType() == ical.ValueBinary {
+				size += int64(len(prop.Value))
+			}
+		}
+	}
+	return size
+}
+
+func newManagedAttachment(managedID string, attachment *ManagedAttachment) *ical.Prop {
+	a := models.Attachment{
+		ManagedID: pgtype.Text{String: managedID, Valid: true},
+		MediaType: pgtype.Text{String: attachment.MediaType, Valid: attachment.MediaType != ""},
+		Filename:  pgtype.Text{String: attachment.Filename, Valid: attachment.Filename != ""},
+		Content:   attachment.Content,
+	}
+	return a.ToDomain()
+}
+
+func (s *caldavServer) DeleteCalendarObject(ctx context.Context, objPath string) error {
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+	homeSetPath, err := s.CalendarHomeSetPath(ctx)
+	if err != nil {
+		return err
+	}
+	// go-webdav routes DELETE of collections here as well
+	rel := strings.Trim(strings.TrimPrefix(objPath, homeSetPath), "/")
+	if rel == "" {
+		return webdav.NewHTTPError(http.StatusForbidden, fmt.Errorf("calendar home set can't be deleted"))
+	}
+	if !strings.Contains(rel, "/") {
+		return s.deleteCalendar(ctx, userID, objPath)
+	}
+
+	folderID, name, err := s.objectName(ctx, objPath)
+	if err != nil {
+		return err
+	}
+
+	conds := conditionsFromContext(ctx)
+	return postgres.HTTPError(s.repo.DeleteCalendarObject(ctx, userID, folderID, name, conds.IfMatch))
+}
+
+// MoveCalendarObject moves the object to another name or calendar, keeping
+// its UID.
+func (s *caldavServer) MoveCalendarObject(
+	ctx context.Context,
+	src, dst string,
+	overwrite bool,
+) (*caldav.CalendarObject, bool, error) {
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	folderID, name, err := s.objectName(ctx, src)
+	if err != nil {
+		return nil, false, err
+	}
+	if path.Clean(dst) == path.Clean(src) {
+		return nil, false, webdav.NewHTTPError(http.StatusForbidden, fmt.Errorf("source and destination are the same"))
+	}
+
+	if _, err := s.repo.GetCalendarObjectInfo(ctx, userID, folderID, name); err != nil {
+		return nil, false, postgres.HTTPError(err)
+	}
+	cal, err := s.repo.GetCalendar(ctx, folderID, name, nil)
+	if err != nil {
+		return nil, false, postgres.HTTPError(err)
+	}
+	eventType, uid, err := caldav.ValidateCalendarObject(cal)
+	if err != nil {
+		return nil, false, err
+	}
+	dstFolderID, err := s.checkCopyDestination(ctx, eventType, dst)
+	if err != nil {
+		return nil, false, err
+	}
+	dstName := path.Base(dst)
+
+	created, err := s.checkOverwrite(ctx, userID, dstFolderID, dstName, overwrite)
+	if err != nil {
+		return nil, false, err
+	}
+	// Within a calendar the object itself is the only one holding its UID,
+	// an overwritten destination is removed along with the move
+	if dstFolderID != folderID {
+		if _, err := s.checkUIDHolder(ctx, userID, dstFolderID, dstName, uid); err != nil {
+			return nil, false, err
+		}
+	}
+
+	conds := conditionsFromContext(ctx)
+	err = s.repo.MoveCalendarObject(ctx, userID, folderID, name, dstFolderID, dstName, conds.IfMatch)
+	if err != nil {
+		return nil, false, postgres.HTTPError(err)
+	}
+
+	obj, err := s.repo.GetCalendarObjectInfo(ctx, userID, dstFolderID, dstName)
+	if err != nil {
+		return nil, false, postgres.HTTPError(err)
+	}
+	obj.Path = dst
+	return obj, created, nil
+}
+
+// CopyCalendarObject stores a copy of the object, with the content of its
+// managed attachments, under the destination. The copy keeps the UID, so it
+// can't be made within the same calendar.
+func (s *caldavServer) CopyCalendarObject(
+	ctx context.Context,
+	src, dst string,
+	overwrite bool,
+) (*caldav.CalendarObject, bool, error) {
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	folderID, name, err := s.objectName(ctx, src)
+	if err != nil {
+		return nil, false, err
+	}
+	if path.Clean(dst) == path.Clean(src) {
+		return nil, false, webdav.NewHTTPError(http.StatusForbidden, fmt.Errorf("source and destination are the same"))
+	}
+
+	info, data, err := s.repo.GetCalendarObjectData(ctx, userID, folderID, name)
+	if err != nil {
+		return nil, false, postgres.HTTPError(err)
+	}
+	// The copy is only stored if the source is still at the version read,
+	// which is the one If-Match names
+	var source *models.ObjectVersion
+	if ifMatch := conditionsFromContext(ctx).IfMatch; ifMatch.IsSet() && !ifMatch.IsWildcard() {
+		wantEtag, err := ifMatch.ETag()
+		if err != nil {
+			return nil, false, webdav.NewHTTPError(http.StatusBadRequest, err)
+		}
+		if wantEtag != info.ETag {
+			return nil, false, webdav.NewHTTPError(
+				http.StatusPreconditionFailed,
+				fmt.Errorf("If-Match header is set and ETag does not match for calendar object %s", src),
+			)
+		}
+		source = &models.ObjectVersion{FolderID: folderID, Name: name, ETag: info.ETag}
+	}
+
+	cal, err := s.repo.GetCalendar(ctx, folderID, name, nil)
+	if err != nil {
+		return nil, false, postgres.HTTPError(err)
+	}
+	eventType, _, err := caldav.ValidateCalendarObject(cal)
+	if err != nil {
+		return nil, false, err
+	}
+	dstFolderID, err := s.checkCopyDestination(ctx, eventType, dst)
+	if err != nil {
+		return nil, false, err
+	}
+	created, err := s.checkOverwrite(ctx, userID, dstFolderID, path.Base(dst), overwrite)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, child := range cal.Children {
+		attachments := child.Props.Values(ical.PropAttach)
+		for i := range attachments {
+			managedID := attachments[i].Params.Get(models.ParamManagedID)
+			if managedID == "" || attachments[i].ValueType() == ical.ValueBinary {
+				continue
+			}
+			a, err := s.repo.GetAttachment(ctx, userID, folderID, name, managedID)
+			if err != nil {
+				return nil, false, postgres.HTTPError(err)
+			}
+			attachments[i] = *newManagedAttachment(managedID, &ManagedAttachment{
+				MediaType: a.MediaType.String,
+				Filename:  a.Filename.String,
+				Content:   a.Content,
+			})
+		}
+	}
+
+	var opts caldav.PutCalendarObjectOptions
+	if created {
+		opts.IfNoneMatch = "*"
+	}
+	if data != nil {
+		// The copy keeps the data of the source as it was put
+		ctx = withCalendarBody(ctx, data)
+	}
+	obj, err := s.putCalendarObject(ctx, dst, cal, &opts, !created, source)
+	if err != nil {
+		return nil, false, err
+	}
+	return obj, created, nil
+}
+
+// checkCopyDestination returns the folder of the destination of a COPY or
+// MOVE, once it's known to support the component type of the object.
+func (s *caldavServer) checkCopyDestination(ctx context.Context, eventType, dst string) (int, error) {
+	dir := path.Dir(dst) + "/"
+	folderID, ok := s.collectionFolderID(ctx, dir)
+	if !ok {
+		return 0, webdav.NewHTTPError(http.StatusConflict, fmt.Errorf("calendar for path: %s not found", dir))
+	}
+	target, err := s.GetCalendar(ctx, dir)
+	if err != nil {
+		return 0, webdav.NewHTTPError(http.StatusConflict, err)
+	}
+	if !slices.Contains(target.SupportedComponentSet, eventType) {
+		return 0, NewPreconditionError(http.StatusForbidden, supportedCalendarComponentName)
+	}
+	return folderID, nil
+}
+
+// checkOverwrite reports whether the destination of a COPY or MOVE is to be
+// created, failing if it exists and the Overwrite header forbids replacing it.
+func (s *caldavServer) checkOverwrite(
+	ctx context.Context,
+	userID string,
+	folderID int,
+	name string,
+	overwrite bool,
+) (bool, error) {
+	_, err := s.repo.GetCalendarObjectInfo(ctx, userID, folderID, name)
+	switch {
+	case err == nil && !overwrite:
+		return false, webdav.NewHTTPError(http.StatusPreconditionFailed, fmt.Errorf("object %s exists", name))
+	case err == nil:
+		return false, nil
+	case !errors.Is(err, postgres.ErrNotFound):
+		return false, postgres.HTTPError(err)
+	}
+	return true, nil
+}
+
+func (s *caldavServer) deleteCalendar(ctx context.Context, userID, urlPath string) error {
+	folderID, err := strconv.Atoi(path.Base(urlPath))
+	if err != nil {
+		return webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("calendar for path: %s not found", urlPath))
+	}
+	return postgres.HTTPError(s.repo.DeleteCalendar(ctx, userID, folderID))
+}
+
+func (s *caldavServer) FreeBusyQuery(ctx context.Context, urlPath string, start, end time.Time) (*ical.Calendar, error) {
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	folderID, err := strconv.Atoi(path.Base(urlPath))
+	if err != nil {
+		return nil, webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("calendar for path: %s not found", urlPath))
+	}
+
+	periods, recurring, err := s.repo.FindBusyPeriods(ctx, userID, folderID, start, end)
+	if err != nil {
+		return nil, postgres.HTTPError(err)
+	}
+	for _, name := range recurring {
+		cal, err := s.repo.GetCalendar(ctx, folderID, name, nil)
+		if err != nil {
+			return nil, postgres.HTTPError(err)
+		}
+		periods = append(periods, models.ScanBusyPeriods(models.ExpandCalendar(cal, start, end))...)
+	}
+	return models.NewFreeBusy(start, end, periods), nil
+}
+
+const syncTokenPrefix = "http://dav-go/ns/sync/"
+
+func formatSyncToken(folderID int, revision int64) string {
+	return fmt.Sprintf("%s%d/%d", syncTokenPrefix, folderID, revision)
+}
+
+// parseSyncToken returns the revision of a sync token issued for the folder.
+func parseSyncToken(token string, folderID int) (pgtype.Int8, error) {
+	if token == "" {
+		return pgtype.Int8{Valid: false}, nil
+	}
+	invalid := NewPreconditionError(http.StatusForbidden, validSyncTokenName)
+
+	rest, ok := strings.CutPrefix(token, syncTokenPrefix)
+	if !ok {
+		return pgtype.Int8{}, invalid
+	}
+	folder, revision, ok := strings.Cut(rest, "/")
+	if !ok || folder != strconv.Itoa(folderID) {
+		return pgtype.Int8{}, invalid
+	}
+	rev, err := strconv.ParseInt(revision, 10, 64)
+	if err != nil || rev < 0 {
+		return pgtype.Int8{}, invalid
+	}
+	return pgtype.Int8{Int64: rev, Valid: true}, nil
+}
+
+func (s *caldavServer) SyncCollection(ctx context.Context, urlPath string, query *SyncQuery) (*SyncResponse, error) {
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	homeSetPath, _ := s.CalendarHomeSetPath(ctx)
+	folderID, err := strconv.Atoi(path.Base(urlPath))
+	if err != nil {
+		return nil, webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("calendar for path: %s not found", urlPath))
+	}
+	revision, err := parseSyncToken(query.SyncToken, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := s.repo.SyncCalendarObjects(ctx, userID, folderID, revision, query.Limit)
+	if err != nil {
+		return nil, postgres.HTTPError(err)
+	}
+	if revision.Valid {
+		// Tokens from the future belong to a recreated database, tokens older
+		// than the change log have expired
+		expired := changes.MinRevision.Valid && revision.Int64 < changes.MinRevision.Int64-1
+		if revision.Int64 > changes.Revision || expired {
+			return nil, NewPreconditionError(http.StatusForbidden, validSyncTokenName)
+		}
+	}
+
+	resp := &SyncResponse{
+		SyncToken: formatSyncToken(folderID, changes.Revision),
+		Changed:   changes.Changed,
+		Truncated: changes.Truncated,
+	}
+	if query.WithData {
+		if resp.Changed, err = s.calendarsData(ctx, homeSetPath, folderID, resp.Changed, nil); err != nil {
+			return nil, err
+		}
+	} else {
+		for i, obj := range resp.Changed {
+			resp.Changed[i].Path = path.Join(homeSetPath, strconv.Itoa(folderID), obj.Path)
+		}
+	}
+	for _, name := range changes.Removed {
+		resp.Removed = append(resp.Removed, path.Join(homeSetPath, strconv.Itoa(folderID), name))
+	}
+	return resp, nil
+}
+
+func (s *caldavServer) CollectionProps(ctx context.Context, urlPath string) (map[xml.Name]string, error) {
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	folderID, ok := s.collectionFolderID(ctx, urlPath)
+	if !ok {
+		return nil, nil
+	}
+
+	f, err := s.repo.GetCalendarFolder(ctx, userID, folderID)
+	if err != nil {
+		return nil, postgres.HTTPError(err)
+	}
+	props := map[xml.Name]string{
+		getCTagName:   strconv.FormatInt(f.Revision, 10),
+		syncTokenName: formatSyncToken(folderID, f.Revision),
+	}
+	if f.Color.Valid {
+		props[calendarColorName] = f.Color.String
+	}
+	if f.Order.Valid {
+		props[calendarOrderName] = strconv.Itoa(int(f.Order.Int32))
+	}
+	if f.Timezone.Valid {
+		props[calendarTimezoneName] = f.Timezone.String
+	}
+	return props, nil
+}
+
+func (s *caldavServer) PatchCollectionProps(ctx context.Context, urlPath string, props map[xml.Name]*string) error {
+	userID, err := s.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+	folderID, ok := s.collectionFolderID(ctx, urlPath)
+	if !ok {
+		return webdav.NewHTTPError(http.StatusForbidden, fmt.Errorf("properties of %s can't be changed", urlPath))
+	}
+
+	text := func(value *string) *pgtype.Text {
+		if value == nil {
+			return &pgtype.Text{}
+		}
+		return &pgtype.Text{String: strings.TrimSpace(*value), Valid: true}
+	}
+	var patch models.FolderPatch
+	for name, value := range props {
+		switch name {
+		case displayNameName:
+			patch.Name = text(value)
+		case calendarDescriptionName:
+			patch.Description = text(value)
+		case calendarColorName:
+			patch.Color = text(value)
+		case calendarTimezoneName:
+			patch.Timezone = text(value)
+		case calendarOrderName:
+			patch.Order = &pgtype.Int4{}
+			if value != nil {
+				order, err := strconv.ParseInt(strings.TrimSpace(*value), 10, 32)
+				if err != nil {
+					return webdav.NewHTTPError(http.StatusConflict, err)
+				}
+				patch.Order = &pgtype.Int4{Int32: int32(order), Valid: true}
+			}
+		}
+	}
+	return postgres.HTTPError(s.repo.UpdateCalendar(ctx, userID, folderID, &patch))
+}
+
+// collectionFolderID returns the folder of a calendar collection path.
+func (s *caldavServer) collectionFolderID(ctx context.Context, urlPath string) (int, bool) {
+	homeSetPath, _ := s.CalendarHomeSetPath(ctx)
+	rel := strings.Trim(strings.TrimPrefix(urlPath, homeSetPath), "/")
+	if rel == "" || strings.Contains(rel, "/") {
+		return 0, false
+	}
+	folderID, err := strconv.Atoi(rel)
+	if err != nil {
+		return 0, false
+	}
+	return folderID, true
+}
+
 func (s *caldavServer) GetPrivileges(ctx context.Context) []string {
 	return []string{"all", "read", "write", "write-properties", "write-content", "unlock", "bind", "unbind", "write-acl", "read-acl", "read-current-user-privilege-set"}
 }
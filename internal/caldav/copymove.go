@@ -0,0 +1,62 @@
+package caldav
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/ceres919/go-webdav"
+	"github.com/ceres919/go-webdav/caldav"
+)
+
+// CopyMoveBackend is implemented by backends supporting COPY and MOVE of
+// calendar object resources. created is false if the destination was
+// overwritten.
+type CopyMoveBackend interface {
+	CopyCalendarObject(ctx context.Context, src, dst string, overwrite bool) (obj *caldav.CalendarObject, created bool, err error)
+	MoveCalendarObject(ctx context.Context, src, dst string, overwrite bool) (obj *caldav.CalendarObject, created bool, err error)
+}
+
+func (h *Handler) serveCopyMove(w http.ResponseWriter, r *http.Request) error {
+	backend, ok := h.Backend.(CopyMoveBackend)
+	if !ok {
+		return webdav.NewHTTPError(http.StatusNotImplemented, nil)
+	}
+
+	dest, err := url.Parse(r.Header.Get("Destination"))
+	if err != nil || dest.Path == "" {
+		return webdav.NewHTTPError(http.StatusBadRequest, fmt.Errorf("missing or malformed Destination header"))
+	}
+	overwrite := true
+	switch r.Header.Get("Overwrite") {
+	case "", "T":
+	case "F":
+		overwrite = false
+	default:
+		return webdav.NewHTTPError(http.StatusBadRequest, fmt.Errorf("malformed Overwrite header"))
+	}
+
+	copyMove := backend.MoveCalendarObject
+	if r.Method == "COPY" {
+		copyMove = backend.CopyCalendarObject
+	}
+	obj, created, err := copyMove(r.Context(), r.URL.Path, dest.Path, overwrite)
+	if err != nil {
+		return err
+	}
+
+	if obj.ETag != "" {
+		w.Header().Set("ETag", strconv.Quote(obj.ETag))
+	}
+	if obj.Path != "" {
+		w.Header().Set("Location", obj.Path)
+	}
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return nil
+}
@@ -1,11 +1,15 @@
 package db
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"path"
 	"strconv"
+	"strings"
+	"time"
 
 	backend "github.com/Raimguzhinov/dav-go/internal/caldav"
 	"github.com/Raimguzhinov/dav-go/internal/caldav/db/models"
@@ -15,6 +19,7 @@ import (
 	"github.com/ceres919/go-webdav"
 	"github.com/ceres919/go-webdav/caldav"
 	"github.com/emersion/go-ical"
+	"github.com/jackc/pgx/v5/pgtype"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -30,27 +35,71 @@ func NewRepository(client *postgres.Postgres, logger *logger.Logger) backend.Rep
 	}
 }
 
-func (r *repository) CreateCalendar(ctx context.Context, homeSetPath string, calendar *caldav.Calendar) error {
+func (r *repository) CreateCalendar(
+	ctx context.Context,
+	userID, homeSetPath string,
+	calendar *caldav.Calendar,
+) error {
 	r.logger.Debug("postgres.CreateCalendar")
 
 	var f models.Folder
 
-	err := r.client.Pool.QueryRow(ctx, `
+	tx, err := r.client.NewTx(ctx)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.CreateCalendar", logger.Err(err))
+		return err
+	}
+	defer func(tx *postgres.Tx, ctx context.Context) {
+		_ = tx.Rollback(ctx)
+	}(tx, ctx)
+
+	// The first calendar of a principal becomes its default one, concurrent
+	// creations of the principal are serialized so that only one of them does
+	_, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('caldav.calendar_folder:' || $1))`, userID)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.CreateCalendar", logger.Err(err))
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `
 		INSERT INTO caldav.calendar_folder
-			(name, description, types, max_size)
-		VALUES ($1, $2, $3, $4)
+			(name, description, types, max_size, is_default)
+		VALUES ($1, $2, $3, $4, NOT EXISTS (
+			SELECT 1 FROM caldav.access WHERE user_id = $5 AND owner = B'1'
+		))
 		RETURNING id
-	`, calendar.Name, calendar.Description, calendar.SupportedComponentSet, calendar.MaxResourceSize).Scan(&f.ID)
+	`, calendar.Name, calendar.Description, calendar.SupportedComponentSet, calendar.MaxResourceSize,
+		userID,
+	).Scan(&f.ID)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.CreateCalendar", logger.Err(err))
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO caldav.access
+			(calendar_folder_id, user_id, owner, read, write)
+		VALUES ($1, $2, B'1', B'1', B'1')
+	`, f.ID, userID)
 	if err != nil {
 		err = r.client.ToPgErr(err)
 		r.logger.Error("postgres.CreateCalendar", logger.Err(err))
 		return err
 	}
+
+	if err = tx.Commit(ctx); err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.CreateCalendar", logger.Err(err))
+		return err
+	}
 	calendar.Path = path.Join(homeSetPath, strconv.Itoa(f.ID))
 	return nil
 }
 
-func (r *repository) FindCalendars(ctx context.Context) ([]caldav.Calendar, error) {
+func (r *repository) FindCalendars(ctx context.Context, userID string) ([]caldav.Calendar, error) {
 	r.logger.Debug("postgres.FindCalendars")
 
 	rows, err := r.client.Pool.Query(ctx, `
@@ -62,11 +111,14 @@ func (r *repository) FindCalendars(ctx context.Context) ([]caldav.Calendar, erro
 			f.max_size AS size
 		FROM
 			caldav.calendar_folder f
+			JOIN caldav.access a ON a.calendar_folder_id = f.id
+		WHERE
+			a.user_id = $1 AND a.read = B'1'
 		GROUP BY
 			f.id, f.name, f.description
 		ORDER BY
 			f.id 
-	`)
+	`, userID)
 	if err != nil {
 		err = r.client.ToPgErr(err)
 		r.logger.Error("postgres.FindCalendars", logger.Err(err))
@@ -89,121 +141,345 @@ func (r *repository) FindCalendars(ctx context.Context) ([]caldav.Calendar, erro
 	return calendars, nil
 }
 
-func (r *repository) GetCalendarObjectInfo(ctx context.Context, uid string) (*caldav.CalendarObject, error) {
+func (r *repository) GetCalendarObjectInfo(
+	ctx context.Context,
+	userID string,
+	folderID int,
+	name string,
+) (*caldav.CalendarObject, error) {
 	r.logger.Debug("postgres.GetCalendarObjectInfo")
 
 	var calendar caldav.CalendarObject
 
 	if err := r.client.Pool.QueryRow(ctx, `
 		SELECT
-			etag, modified_at, size
+			c.etag, c.modified_at, c.size
 		FROM
-			caldav.calendar_file
+			caldav.calendar_file c
+			JOIN caldav.access a ON a.calendar_folder_id = c.calendar_folder_id
 		WHERE
-			uid = $1
-	`, uid).Scan(
+			c.calendar_folder_id = $1 AND c.name = $2 AND a.user_id = $3 AND a.read = B'1'
+	`, folderID, name, userID).Scan(
 		&calendar.ETag, &calendar.ModTime, &calendar.ContentLength,
 	); err != nil {
 		err = r.client.ToPgErr(err)
-		r.logger.Error("postgres.GetCalendarObjectInfo", logger.Err(err))
+		// Missing objects are expected, PUT looks them up to find conflicts
+		if !r.client.IsNoRows(err) {
+			r.logger.Error("postgres.GetCalendarObjectInfo", logger.Err(err))
+		}
 		return nil, err
 	}
 
+	calendar.Path = path.Join(strconv.Itoa(folderID), name)
 	return &calendar, nil
 }
 
-func (r *repository) UpgradeCalendarObject(
+// GetCalendarObjectData returns the object with the iCalendar data it is
+// stored with, nil for objects stored by their columns only.
+func (r *repository) GetCalendarObjectData(
+	ctx context.Context,
+	userID string,
+	folderID int,
+	name string,
+) (*caldav.CalendarObject, []byte, error) {
+	r.logger.Debug("postgres.GetCalendarObjectData")
+
+	var calendar caldav.CalendarObject
+	var data []byte
+
+	if err := r.client.Pool.QueryRow(ctx, `
+		SELECT
+			c.etag, c.modified_at, c.size, c.data
+		FROM
+			caldav.calendar_file c
+			JOIN caldav.access a ON a.calendar_folder_id = c.calendar_folder_id
+		WHERE
+			c.calendar_folder_id = $1 AND c.name = $2 AND a.user_id = $3 AND a.read = B'1'
+	`, folderID, name, userID).Scan(
+		&calendar.ETag, &calendar.ModTime, &calendar.ContentLength, &data,
+	); err != nil {
+		err = r.client.ToPgErr(err)
+		if !r.client.IsNoRows(err) {
+			r.logger.Error("postgres.GetCalendarObjectData", logger.Err(err))
+		}
+		return nil, nil, err
+	}
+
+	calendar.Path = path.Join(strconv.Itoa(folderID), name)
+	return &calendar, data, nil
+}
+
+// FindCalendarObjectByUID returns the object of the folder holding the
+// iCalendar UID, which is unique within a folder.
+func (r *repository) FindCalendarObjectByUID(
 	ctx context.Context,
-	uid, eventType string,
-	object *caldav.CalendarObject,
-	opts *caldav.PutCalendarObjectOptions,
+	userID string,
+	folderID int,
+	uid string,
 ) (*caldav.CalendarObject, error) {
-	r.logger.Debug("postgres.UpgradeCalendarObject")
+	r.logger.Debug("postgres.FindCalendarObjectByUID")
+
+	var calendar caldav.CalendarObject
+	var name string
+
+	if err := r.client.Pool.QueryRow(ctx, `
+		SELECT
+			c.name, c.etag, c.modified_at, c.size
+		FROM
+			caldav.calendar_file c
+			JOIN caldav.access a ON a.calendar_folder_id = c.calendar_folder_id
+		WHERE
+			c.calendar_folder_id = $1 AND c.ical_uid = $2 AND a.user_id = $3 AND a.read = B'1'
+	`, folderID, uid, userID).Scan(
+		&name, &calendar.ETag, &calendar.ModTime, &calendar.ContentLength,
+	); err != nil {
+		err = r.client.ToPgErr(err)
+		if !r.client.IsNoRows(err) {
+			r.logger.Error("postgres.FindCalendarObjectByUID", logger.Err(err))
+		}
+		return nil, err
+	}
+
+	calendar.Path = path.Join(strconv.Itoa(folderID), name)
+	return &calendar, nil
+}
+
+func (r *repository) GetAttachment(
+	ctx context.Context,
+	userID string,
+	folderID int,
+	name, managedID string,
+) (*models.Attachment, error) {
+	r.logger.Debug("postgres.GetAttachment")
+
+	var attachment models.Attachment
+
+	if err := r.client.Pool.QueryRow(ctx, `
+		SELECT
+			at.managed_id, at.media_type, at.filename, at.content, octet_length(at.content)
+		FROM
+			caldav.attachment at
+			JOIN caldav.event_component ec ON ec.id = at.event_component_id
+			JOIN caldav.calendar_file c ON c.uid = ec.calendar_file_uid
+			JOIN caldav.access a ON a.calendar_folder_id = c.calendar_folder_id
+		WHERE
+			c.calendar_folder_id = $1 AND c.name = $2 AND at.managed_id::text = $3
+			AND a.user_id = $4 AND a.read = B'1'
+		LIMIT 1
+	`, folderID, name, managedID, userID).Scan(
+		&attachment.ManagedID, &attachment.MediaType, &attachment.Filename,
+		&attachment.Content, &attachment.Size,
+	); err != nil {
+		if r.client.IsNoRows(err) {
+			return nil, fmt.Errorf("%w: attachment %s of %s", postgres.ErrNotFound, managedID, name)
+		}
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.GetAttachment", logger.Err(err))
+		return nil, err
+	}
+
+	return &attachment, nil
+}
+
+// UpgradeCalendarObjects creates or updates the objects in a single
+// transaction, none of them is stored if one fails.
+func (r *repository) UpgradeCalendarObjects(
+	ctx context.Context,
+	userID string,
+	writes []models.CalendarObjectWrite,
+) error {
+	r.logger.Debug("postgres.UpgradeCalendarObjects")
+
+	tx, err := r.client.NewTx(ctx)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.UpgradeCalendarObjects", logger.Err(err))
+		return err
+	}
+	defer func(tx *postgres.Tx, ctx context.Context) {
+		_ = tx.Rollback(ctx)
+	}(tx, ctx)
+
+	for i := range writes {
+		if err = r.upgradeCalendarObject(ctx, tx, userID, &writes[i]); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.UpgradeCalendarObjects", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
+func (r *repository) upgradeCalendarObject(
+	ctx context.Context,
+	tx *postgres.Tx,
+	userID string,
+	w *models.CalendarObjectWrite,
+) error {
+	r.logger.Debug("postgres.upgradeCalendarObject")
 
-	ifNoneMatch := opts.IfNoneMatch.IsWildcard()
-	ifMatch := opts.IfMatch.IsSet()
+	object := w.Object
+	ifNoneMatch := w.Opts.IfNoneMatch.IsWildcard()
+	ifMatch := w.Opts.IfMatch.IsSet()
 
 	var wantEtag string
 	var err error
 
 	if ifMatch {
-		wantEtag, err = opts.IfMatch.ETag()
+		wantEtag, err = w.Opts.IfMatch.ETag()
 		if err != nil {
-			return nil, webdav.NewHTTPError(http.StatusBadRequest, err)
+			return webdav.NewHTTPError(http.StatusBadRequest, err)
 		}
 	}
 
 	var cal models.Calendar
 	var f models.Folder
 
-	folderDir, _ := path.Split(object.Path)
+	folderDir, name := path.Split(object.Path)
 	f.ID, err = strconv.Atoi(path.Base(folderDir))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	cal.Version, err = object.Data.Component.Props.Text(ical.PropVersion)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	cal.Product, err = object.Data.Component.Props.Text(ical.PropProductID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	tx, err := r.client.NewTx(ctx)
-	if err != nil {
-		err = r.client.ToPgErr(err)
-		r.logger.Error("postgres.UpgradeCalendarObject", logger.Err(err))
-		return nil, err
+	if err = r.checkWriteAccess(ctx, tx, userID, f.ID); err != nil {
+		return err
 	}
-	defer func(tx *postgres.Tx, ctx context.Context) {
-		_ = tx.Rollback(ctx)
-	}(tx, ctx)
 
-	_, err = tx.Exec(
+	if w.Source != nil {
+		var currentEtag string
+		err = tx.QueryRow(ctx, `
+			SELECT
+				c.etag
+			FROM
+				caldav.calendar_file c
+				JOIN caldav.access a ON a.calendar_folder_id = c.calendar_folder_id
+			WHERE
+				c.calendar_folder_id = $1 AND c.name = $2 AND a.user_id = $3 AND a.read = B'1'
+			FOR SHARE OF c
+		`, w.Source.FolderID, w.Source.Name, userID).Scan(&currentEtag)
+		if err != nil {
+			if r.client.IsNoRows(err) {
+				return fmt.Errorf("%w: calendar object %s", postgres.ErrNotFound, w.Source.Name)
+			}
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.upgradeCalendarObject", logger.Err(err))
+			return err
+		}
+		if currentEtag != w.Source.ETag {
+			return fmt.Errorf(
+				"%w: If-Match header is set and ETag does not match for calendar object %s",
+				postgres.ErrPreconditionFailed, w.Source.Name,
+			)
+		}
+	}
+
+	if w.Replace {
+		_, err = tx.Exec(ctx, `
+			DELETE FROM caldav.calendar_file
+			WHERE calendar_folder_id = $1 AND name = $2 AND ical_uid <> $3
+		`, f.ID, name, w.UID)
+		if err != nil {
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.upgradeCalendarObject", logger.Err(err))
+			return err
+		}
+	}
+
+	if len(w.Series) > 0 {
+		// Series split off the object are named after its UID followed by the
+		// RECURRENCE-ID of every split
+		_, err = tx.Exec(ctx, `
+			DELETE FROM caldav.calendar_file
+			WHERE calendar_folder_id = $1
+				AND starts_with(ical_uid, $2 || '-')
+				AND substr(ical_uid, length($2) + 1) ~ '^(-[0-9]{8}T[0-9]{6}Z)+$'
+				AND name <> ALL($3)
+		`, f.ID, w.UID, w.Series)
+		if err != nil {
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.upgradeCalendarObject", logger.Err(err))
+			return err
+		}
+	}
+
+	// Components refer to the internal uid of the file, not to its UID
+	var fileUID string
+
+	err = tx.QueryRow(
 		ctx, `
-		CALL caldav.create_or_update_calendar_file($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-	`, uid, eventType, f.ID, object.ETag, wantEtag, object.ModTime, object.ContentLength,
-		cal.Version, cal.Product, ifNoneMatch, ifMatch,
-	)
+		CALL caldav.create_or_update_calendar_file(
+			NULL, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, p_data => $13
+		)
+	`, name, w.UID, w.EventType, f.ID, object.ETag, wantEtag, object.ModTime, object.ContentLength,
+		cal.Version, cal.Product, ifNoneMatch, ifMatch, w.Data,
+	).Scan(&fileUID)
 	if err != nil {
 		err = r.client.ToPgErr(err)
-		r.logger.Error("postgres.UpgradeCalendarObject", logger.Err(err))
-		return nil, err
+		r.logger.Error("postgres.upgradeCalendarObject", logger.Err(err))
+		return err
 	}
 
 	eg := errgroup.Group{}
 	batch := r.client.NewBatch()
 	recurParent := utils.NewOnceValue()
 	recurCnt := utils.NewOnceValue()
-	recurCnt.Set(len(object.Data.Component.Children))
+
+	var timezones []*ical.Component
+	for _, child := range object.Data.Component.Children {
+		if child.Name == ical.CompTimezone {
+			timezones = append(timezones, child)
+		}
+	}
+	locs := models.NewLocations(timezones)
+	recurCnt.Set(len(object.Data.Component.Children) - len(timezones))
+
+	batch.Queue(`DELETE FROM caldav.calendar_timezone WHERE calendar_file_uid = $1`, fileUID)
+	for _, tz := range models.ScanTimezones(object.Data) {
+		batch.Queue(`
+			INSERT INTO caldav.calendar_timezone
+			(
+				calendar_file_uid,
+				tzid,
+				definition
+			) VALUES ($1, $2, $3)
+			ON CONFLICT (calendar_file_uid, tzid) DO UPDATE SET
+				definition = EXCLUDED.definition
+		`, fileUID, tz.TZID, tz.Definition)
+	}
 
 	for _, child := range object.Data.Component.Children {
-		if child.Name == ical.CompEvent || child.Name == ical.CompToDo {
+		if models.ComponentType(child.Name).Valid {
 			eg.Go(func() error {
-				return r.createEvent(ctx, tx, batch, uid, recurParent, recurCnt, child)
+				return r.createEvent(ctx, tx, batch, fileUID, locs, recurParent, recurCnt, w.ThisAndFuture, child)
 			})
 		}
 	}
 
 	if err = eg.Wait(); err != nil {
-		return nil, err
+		return err
 	}
 
 	res := tx.SendBatch(ctx, batch.Batch)
 	if err := res.Close(); err != nil {
 		err = r.client.ToPgErr(err)
 		r.logger.Error("postgres.createEvent send batch", logger.Err(err))
-		return nil, err
+		return err
 	}
 
-	if err = tx.Commit(ctx); err != nil {
-		err = r.client.ToPgErr(err)
-		r.logger.Error("postgres.UpgradeCalendarObject", logger.Err(err))
-		return nil, err
-	}
-	return object, nil
+	return nil
 }
 
 func (r *repository) createEvent(
@@ -211,15 +487,17 @@ func (r *repository) createEvent(
 	tx *postgres.Tx,
 	batch *postgres.Batch,
 	uid string,
+	locs models.Locations,
 	recurParent *utils.OnceValue,
 	recurCnt *utils.OnceValue,
+	thisAndFuture bool,
 	event *ical.Component,
 ) error {
 	r.logger.Debug("postgres.createEvent")
 
 	var parentID int
 
-	e := models.ScanEvent(event)
+	e := models.ScanEvent(event, locs)
 
 	err := tx.QueryRow(ctx, `
 		INSERT INTO caldav.event_component
@@ -246,8 +524,13 @@ func (r *repository) createEvent(
 			event_transparency,
 			todo_completed,
 			todo_percent_complete,
-			properties
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
+			properties,
+			start_tzid,
+			end_tzid,
+			journal_descriptions,
+			todo_due,
+			todo_due_tzid
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28)
 		ON CONFLICT (calendar_file_uid, created_at) DO UPDATE SET
 			component_type = EXCLUDED.component_type,
 			date_timestamp = EXCLUDED.date_timestamp,
@@ -269,15 +552,22 @@ func (r *repository) createEvent(
 			event_transparency = EXCLUDED.event_transparency,
 			todo_completed = EXCLUDED.todo_completed,
 			todo_percent_complete = EXCLUDED.todo_percent_complete,
-			properties = EXCLUDED.properties
+			properties = EXCLUDED.properties,
+			start_tzid = EXCLUDED.start_tzid,
+			end_tzid = EXCLUDED.end_tzid,
+			journal_descriptions = EXCLUDED.journal_descriptions,
+			todo_due = EXCLUDED.todo_due,
+			todo_due_tzid = EXCLUDED.todo_due_tzid
 		RETURNING id
-	`, uid, e.CompTypeBit,
+	`, uid, e.CompType,
 		e.Timestamp, e.Created, e.LastModified,
 		e.Summary, e.Description, e.Url, e.Organizer,
 		e.Start, e.End,
 		e.Duration, e.AllDay, e.Class, e.Loc, e.Priority,
 		e.Sequence, e.Status, e.Categories, e.Transparent,
 		e.Completed, e.PerCompleted, e.Properties,
+		e.StartTZID, e.EndTZID, e.JournalDescriptions,
+		e.Due, e.DueTZID,
 	).Scan(&parentID)
 	if err != nil {
 		err = r.client.ToPgErr(err)
@@ -285,6 +575,136 @@ func (r *repository) createEvent(
 		return err
 	}
 
+	batch.Queue(`DELETE FROM caldav.attendee WHERE event_component_id = $1`, parentID)
+	for _, a := range e.Attendees {
+		batch.Queue(`
+			INSERT INTO caldav.attendee
+			(
+				event_component_id,
+				email,
+				common_name,
+				directory_entry_ref,
+				language,
+				user_type,
+				sent_by,
+				delegated_from,
+				delegated_to,
+				rsvp,
+				participation_role,
+				participation_status
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		`, parentID, a.Email, a.CommonName, a.DirectoryEntryRef, a.Language, a.UserType,
+			a.SentBy, a.DelegatedFrom, a.DelegatedTo, a.RSVP,
+			a.ParticipationRole, a.ParticipationStatus,
+		)
+	}
+
+	var managedIDs []string
+	for _, a := range e.Attachments {
+		if a.ManagedID.Valid {
+			managedIDs = append(managedIDs, a.ManagedID.String)
+		}
+	}
+	batch.Queue(`
+		DELETE FROM caldav.attachment
+		WHERE event_component_id = $1
+		  AND (managed_id IS NULL OR NOT managed_id::text = ANY($2))
+	`, parentID, managedIDs)
+	for _, a := range e.Attachments {
+		switch {
+		case a.ManagedID.Valid && a.Content == nil:
+			// Managed attachments given by reference keep their content, which
+			// may come from another instance of the same object
+			batch.Queue(`
+				INSERT INTO caldav.attachment
+				(
+					event_component_id,
+					managed_id,
+					media_type,
+					filename,
+					content
+				)
+				SELECT $1, a.managed_id, a.media_type, a.filename, a.content
+				FROM caldav.attachment a
+				JOIN caldav.event_component ec ON ec.id = a.event_component_id
+				WHERE ec.calendar_file_uid = $2
+				  AND a.managed_id = $3
+				LIMIT 1
+				ON CONFLICT (event_component_id, managed_id) DO NOTHING
+			`, parentID, uid, a.ManagedID)
+		case a.ManagedID.Valid:
+			batch.Queue(`
+				INSERT INTO caldav.attachment
+				(
+					event_component_id,
+					managed_id,
+					media_type,
+					filename,
+					content
+				) VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (event_component_id, managed_id) DO UPDATE SET
+					media_type = EXCLUDED.media_type,
+					filename = EXCLUDED.filename,
+					content = EXCLUDED.content
+			`, parentID, a.ManagedID, a.MediaType, a.Filename, a.Content)
+		default:
+			batch.Queue(`
+				INSERT INTO caldav.attachment
+				(
+					event_component_id,
+					media_type,
+					filename,
+					external_url,
+					content
+				) VALUES ($1, $2, $3, $4, $5)
+			`, parentID, a.MediaType, a.Filename, a.ExternalURL, a.Content)
+		}
+	}
+
+	batch.Queue(`DELETE FROM caldav.property WHERE event_component_id = $1`, parentID)
+	for _, prop := range e.ExtraProps {
+		batch.Queue(`
+			INSERT INTO caldav.property
+			(
+				event_component_id,
+				name,
+				params,
+				value
+			) VALUES ($1, $2, $3, $4)
+		`, parentID, prop.Name, prop.Params, prop.Value)
+	}
+
+	batch.Queue(`DELETE FROM caldav.relation WHERE event_component_id = $1`, parentID)
+	for _, rel := range e.Relations {
+		batch.Queue(`
+			INSERT INTO caldav.relation
+			(
+				event_component_id,
+				related_uid,
+				relationship_type
+			) VALUES ($1, $2, $3)
+		`, parentID, rel.RelatedUID, rel.RelationshipType)
+	}
+
+	batch.Queue(`DELETE FROM caldav.alarm WHERE event_component_id = $1`, parentID)
+	for _, a := range e.Alarms {
+		batch.Queue(`
+			INSERT INTO caldav.alarm
+			(
+				event_component_id,
+				action,
+				trigger,
+				trigger_related,
+				summary,
+				description,
+				duration,
+				repeat
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, parentID, a.Action, a.Trigger, a.TriggerRelated,
+			a.Summary, a.Description, a.Duration, a.Repeat,
+		)
+	}
+
 	if val := recurCnt.Get(); val != nil {
 		if cnt := val.(int); cnt == 1 {
 			r.logger.Debug("postgres.createEvent should remove recurrence", slog.Int("parentID", parentID))
@@ -294,9 +714,13 @@ func (r *repository) createEvent(
 		}
 	}
 
-	if rs := models.ScanRecurrence(event); rs != nil {
+	if rs := models.ScanRecurrence(event, locs); rs != nil {
 		var recurrenceID int
 
+		if thisAndFuture {
+			rs.ThisAndFuture = models.BitIsSet
+		}
+
 		err := tx.QueryRow(ctx, `
 			INSERT INTO caldav.recurrence
 			(
@@ -310,8 +734,10 @@ func (r *repository) createEvent(
 				by_month,
 				period_day,
 				by_set_pos,
-				this_and_future
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+				this_and_future,
+				frequency,
+				rule
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 			ON CONFLICT (event_component_id) DO UPDATE SET
 				interval = EXCLUDED.interval,
 				until = EXCLUDED.until,
@@ -322,10 +748,13 @@ func (r *repository) createEvent(
 				by_month = EXCLUDED.by_month,
 				period_day = EXCLUDED.period_day,
 				by_set_pos = EXCLUDED.by_set_pos,
-				this_and_future = EXCLUDED.this_and_future
+				this_and_future = EXCLUDED.this_and_future,
+				frequency = EXCLUDED.frequency,
+				rule = EXCLUDED.rule
 			RETURNING id
 		`, parentID, rs.Interval, rs.Until, rs.Cnt, rs.Wkst, rs.Weekdays,
 			rs.Monthdays, rs.Months, rs.PeriodDay, rs.BySetPos, rs.ThisAndFuture,
+			rs.Frequency, rs.Rule,
 		).Scan(&recurrenceID)
 		if err != nil {
 			err = r.client.ToPgErr(err)
@@ -349,10 +778,25 @@ func (r *repository) createEvent(
 				`, parentID, recurrenceID, ex.Value, "1")
 			}
 		}
+
+		batch.Queue(`DELETE FROM caldav.recurrence_date WHERE recurrence_id = $1`, recurrenceID)
+		for _, d := range rs.Dates {
+			batch.Queue(`
+				INSERT INTO caldav.recurrence_date
+				(
+					recurrence_id,
+					value,
+					end_date,
+					period,
+					tzid,
+					value_type
+				) VALUES ($1, $2, $3, $4, $5, $6)
+			`, recurrenceID, d.Value, d.End, d.Period, d.TZID, d.ValueType)
+		}
 		recurParent.Set(recurrenceID)
 	}
 
-	if ex := models.ScanRecurrenceException(event); ex != nil {
+	if ex := models.ScanRecurrenceException(event, locs); ex != nil {
 		for {
 			r.logger.Debug("getting recurrence id...")
 
@@ -390,168 +834,579 @@ func (r *repository) removeRecurrence(ctx context.Context, tx *postgres.Tx, pare
 	return nil
 }
 
+// GetCalendar returns the calendar of a single object, see GetCalendars.
 func (r *repository) GetCalendar(
 	ctx context.Context,
-	uid string,
+	folderID int,
+	name string,
 	propFilter []string,
 ) (*ical.Calendar, error) {
-	r.logger.Debug("postgres.GetCalendar")
-
-	var cal models.Calendar
-	isNotDeletedExceptions := make(map[int]string)
-
-	if err := r.client.Pool.QueryRow(ctx, `
-		SELECT
-			version,
-			product,
-			scale,
-			method
-		FROM caldav.calendar_property
-		WHERE calendar_file_uid = $1
-	`, uid).Scan(&cal.Version, &cal.Product, &cal.Scale, &cal.Method); err != nil {
-		err = r.client.ToPgErr(err)
-		r.logger.Error("postgres.GetCalendar", logger.Err(err))
+	cals, err := r.GetCalendars(ctx, folderID, []string{name}, propFilter)
+	if err != nil {
 		return nil, err
 	}
+	cal, ok := cals[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: calendar object %s", postgres.ErrNotFound, name)
+	}
+	return cal, nil
+}
+
+// calendarFile is a calendar object being loaded by GetCalendars.
+type calendarFile struct {
+	name    string
+	icalUID string
+	cal     models.Calendar
+}
+
+// calendarEvent is an event component being loaded by GetCalendars.
+type calendarEvent struct {
+	id      int
+	fileUID string
+	event   models.Event
+}
+
+// GetCalendars returns the calendars of the named objects of the folder, keyed
+// by name. Everything is loaded with one query per table, whatever the number
+// of objects and components, names of missing objects are left out. Objects
+// stored with their iCalendar data are decoded from it instead.
+func (r *repository) GetCalendars(
+	ctx context.Context,
+	folderID int,
+	names []string,
+	propFilter []string,
+) (map[string]*ical.Calendar, error) {
+	r.logger.Debug("postgres.GetCalendars")
+
+	cals := make(map[string]*ical.Calendar, len(names))
+	files := make(map[string]*calendarFile)
+	var fileUIDs []string
 
 	rows, err := r.client.Pool.Query(ctx, `
 		SELECT
-			id,
-			component_type,
-			date_timestamp,
-			created_at,
-			last_modified_at,
-			summary,
-			description,
-			url,
-			organizer,
-			start_date,
-			end_date,
-			duration,
-			all_day,
-			class,
-			location,
-			priority,
-			sequence,
-			status,
-			categories,
-			event_transparency,
-			todo_completed,
-			todo_percent_complete,
-			properties
-		FROM caldav.event_component
-		WHERE calendar_file_uid = $1
-	`, uid)
+			c.uid,
+			c.name,
+			c.ical_uid,
+			c.data,
+			p.version,
+			p.product,
+			p.scale,
+			p.method
+		FROM caldav.calendar_file c
+			JOIN caldav.calendar_property p ON p.calendar_file_uid = c.uid
+		WHERE c.calendar_folder_id = $1 AND c.name = ANY($2)
+	`, folderID, names)
 	if err != nil {
 		err = r.client.ToPgErr(err)
-		r.logger.Error("postgres.GetCalendar", logger.Err(err))
+		r.logger.Error("postgres.GetCalendars", logger.Err(err))
 		return nil, err
 	}
-
 	for rows.Next() {
-		var event models.Event
-		var eventID int
+		var f calendarFile
+		var uid string
+		var data []byte
 
 		if err := rows.Scan(
-			&eventID, &event.CompTypeBit, &event.Timestamp, &event.Created, &event.LastModified,
-			&event.Summary, &event.Description, &event.Url, &event.Organizer, &event.Start, &event.End,
-			&event.Duration, &event.AllDay, &event.Class, &event.Loc, &event.Priority, &event.Sequence,
-			&event.Status, &event.Categories, &event.Transparent, &event.Completed, &event.PerCompleted,
-			&event.Properties,
+			&uid, &f.name, &f.icalUID, &data, &f.cal.Version, &f.cal.Product, &f.cal.Scale, &f.cal.Method,
 		); err != nil {
+			rows.Close()
 			err = r.client.ToPgErr(err)
-			r.logger.Error("postgres.GetCalendar", logger.Err(err))
+			r.logger.Error("postgres.GetCalendars", logger.Err(err))
 			return nil, err
 		}
-
-		var rs models.RecurrenceSet
-		recurrenceID, err := r.scanRecurrence(ctx, eventID, &rs)
-		if err != nil {
-			return nil, err
+		if data != nil {
+			cal, err := ical.NewDecoder(bytes.NewReader(data)).Decode()
+			if err != nil {
+				rows.Close()
+				r.logger.Error("postgres.GetCalendars", logger.Err(err))
+				return nil, fmt.Errorf("decode calendar object %s: %w", f.name, err)
+			}
+			cals[f.name] = cal
+			continue
 		}
+		files[uid] = &f
+		fileUIDs = append(fileUIDs, uid)
+	}
+	rows.Close()
+	if len(files) == 0 {
+		return cals, nil
+	}
 
-		subrows, err := r.client.Pool.Query(ctx, `
-			SELECT
-				event_component_id,
-				exception_date,
-				deleted_recurrence
-			FROM
-				caldav.recurrence_exception
-			WHERE
-				recurrence_id = $1
-		`, recurrenceID)
-		if err != nil {
+	tzRows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			calendar_file_uid,
+			tzid,
+			definition
+		FROM caldav.calendar_timezone
+		WHERE calendar_file_uid = ANY($1)
+		ORDER BY tzid
+	`, fileUIDs)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.GetCalendars", logger.Err(err))
+		return nil, err
+	}
+	for tzRows.Next() {
+		var tz models.Timezone
+		var uid string
+
+		if err := tzRows.Scan(&uid, &tz.TZID, &tz.Definition); err != nil {
+			tzRows.Close()
 			err = r.client.ToPgErr(err)
-			r.logger.Error("postgres.GetCalendar", logger.Err(err))
+			r.logger.Error("postgres.GetCalendars", logger.Err(err))
 			return nil, err
 		}
+		files[uid].cal.Timezones = append(files[uid].cal.Timezones, tz)
+	}
+	tzRows.Close()
 
-		for subrows.Next() {
-			var ex models.RecurrenceException
-			var exEventID int
+	events, err := r.scanEvents(ctx, fileUIDs)
+	if err != nil {
+		return nil, err
+	}
+	eventIDs := make([]int, len(events))
+	for i := range events {
+		eventIDs[i] = events[i].id
+	}
 
-			err = subrows.Scan(
-				&exEventID, &ex.Value, &ex.IsDeleted,
-			)
-			if err != nil {
-				err = r.client.ToPgErr(err)
-				r.logger.Error("postgres.GetCalendar", logger.Err(err))
-				return nil, err
-			}
+	recurrences, overrides, err := r.scanRecurrences(ctx, eventIDs)
+	if err != nil {
+		return nil, err
+	}
+	attendees, err := r.scanAttendees(ctx, eventIDs)
+	if err != nil {
+		return nil, err
+	}
+	props, err := r.scanProperties(ctx, eventIDs)
+	if err != nil {
+		return nil, err
+	}
+	relations, err := r.scanRelations(ctx, eventIDs)
+	if err != nil {
+		return nil, err
+	}
+	alarms, err := r.scanAlarms(ctx, eventIDs)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := r.scanAttachments(ctx, eventIDs)
+	if err != nil {
+		return nil, err
+	}
 
-			if ex.IsDeleted == models.BitIsSet {
-				rs.Exceptions = append(rs.Exceptions, &ex)
-			} else if ex.IsDeleted == models.BitNone {
-				isNotDeletedExceptions[exEventID] = ex.ToDomain()
-			}
+	for _, e := range events {
+		event := e.event
+		event.RecurrenceSet = recurrences[e.id]
+		if event.RecurrenceSet == nil {
+			event.RecurrenceSet = &models.RecurrenceSet{}
+		}
+		event.NotDeletedException = overrides[e.id]
+		event.Attendees = attendees[e.id]
+		event.ExtraProps = props[e.id]
+		event.Relations = relations[e.id]
+		event.Alarms = alarms[e.id]
+		event.Attachments = attachments[e.id]
+
+		f := files[e.fileUID]
+		f.cal.Events = append(f.cal.Events, event)
+	}
+
+	for _, f := range files {
+		cals[f.name] = f.cal.ToDomain(f.icalUID)
+	}
+	return cals, nil
+}
+
+func (r *repository) scanEvents(ctx context.Context, fileUIDs []string) ([]calendarEvent, error) {
+	rows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			id,
+			calendar_file_uid,
+			component_type,
+			date_timestamp,
+			created_at,
+			last_modified_at,
+			summary,
+			description,
+			url,
+			organizer,
+			start_date,
+			end_date,
+			duration,
+			all_day,
+			class,
+			location,
+			priority,
+			sequence,
+			status,
+			categories,
+			event_transparency,
+			todo_completed,
+			todo_percent_complete,
+			properties,
+			start_tzid,
+			end_tzid,
+			journal_descriptions,
+			todo_due,
+			todo_due_tzid
+		FROM caldav.event_component
+		WHERE calendar_file_uid = ANY($1)
+		ORDER BY id
+	`, fileUIDs)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.GetCalendars", logger.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []calendarEvent
+	for rows.Next() {
+		var e calendarEvent
+		event := &e.event
+
+		if err := rows.Scan(
+			&e.id, &e.fileUID, &event.CompType, &event.Timestamp, &event.Created, &event.LastModified,
+			&event.Summary, &event.Description, &event.Url, &event.Organizer, &event.Start, &event.End,
+			&event.Duration, &event.AllDay, &event.Class, &event.Loc, &event.Priority, &event.Sequence,
+			&event.Status, &event.Categories, &event.Transparent, &event.Completed, &event.PerCompleted,
+			&event.Properties, &event.StartTZID, &event.EndTZID, &event.JournalDescriptions,
+			&event.Due, &event.DueTZID,
+		); err != nil {
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.GetCalendars", logger.Err(err))
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// scanRecurrences returns the recurrence sets of the events, with their dates
+// and deleted instances, and the RECURRENCE-ID of the events overriding an
+// instance.
+func (r *repository) scanRecurrences(
+	ctx context.Context,
+	eventIDs []int,
+) (map[int]*models.RecurrenceSet, map[int]pgtype.Timestamp, error) {
+	rows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			id,
+			event_component_id,
+			interval,
+			until,
+			count,
+			week_start,
+			by_day,
+			by_month_day,
+			by_month,
+			period_day,
+			by_set_pos,
+			frequency,
+			rule
+		FROM
+			caldav.recurrence
+		WHERE
+			event_component_id = ANY($1)
+	`, eventIDs)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.GetCalendars", logger.Err(err))
+		return nil, nil, err
+	}
+
+	sets := make(map[int]*models.RecurrenceSet)
+	byRecurrence := make(map[int]*models.RecurrenceSet)
+	var recurrenceIDs []int
+	for rows.Next() {
+		var rs models.RecurrenceSet
+		var recurrenceID, eventID int
+
+		if err := rows.Scan(
+			&recurrenceID, &eventID,
+			&rs.Interval, &rs.Until, &rs.Cnt, &rs.Wkst, &rs.Weekdays,
+			&rs.Monthdays, &rs.Months, &rs.PeriodDay, &rs.BySetPos,
+			&rs.Frequency, &rs.Rule,
+		); err != nil {
+			rows.Close()
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.GetCalendars", logger.Err(err))
+			return nil, nil, err
 		}
+		sets[eventID] = &rs
+		byRecurrence[recurrenceID] = &rs
+		recurrenceIDs = append(recurrenceIDs, recurrenceID)
+	}
+	rows.Close()
+
+	overrides := make(map[int]pgtype.Timestamp)
+	if len(recurrenceIDs) == 0 {
+		return sets, overrides, nil
+	}
+
+	dateRows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			recurrence_id,
+			value,
+			end_date,
+			period,
+			tzid,
+			value_type
+		FROM caldav.recurrence_date
+		WHERE recurrence_id = ANY($1)
+		ORDER BY id
+	`, recurrenceIDs)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.GetCalendars", logger.Err(err))
+		return nil, nil, err
+	}
+	for dateRows.Next() {
+		var d models.RecurrenceDate
+		var recurrenceID int
 
-		if _, ok := isNotDeletedExceptions[eventID]; ok {
-			event.NotDeletedException = isNotDeletedExceptions[eventID]
+		if err := dateRows.Scan(&recurrenceID, &d.Value, &d.End, &d.Period, &d.TZID, &d.ValueType); err != nil {
+			dateRows.Close()
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.GetCalendars", logger.Err(err))
+			return nil, nil, err
 		}
+		rs := byRecurrence[recurrenceID]
+		rs.Dates = append(rs.Dates, d)
+	}
+	dateRows.Close()
 
-		event.RecurrenceSet = &rs
-		cal.Events = append(cal.Events, event)
+	exRows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			recurrence_id,
+			event_component_id,
+			exception_date,
+			deleted_recurrence
+		FROM
+			caldav.recurrence_exception
+		WHERE
+			recurrence_id = ANY($1)
+	`, recurrenceIDs)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.GetCalendars", logger.Err(err))
+		return nil, nil, err
 	}
+	defer exRows.Close()
+
+	for exRows.Next() {
+		ex := &models.RecurrenceException{}
+		var recurrenceID, exEventID int
+
+		if err := exRows.Scan(&recurrenceID, &exEventID, &ex.Value, &ex.IsDeleted); err != nil {
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.GetCalendars", logger.Err(err))
+			return nil, nil, err
+		}
 
-	return cal.ToDomain(uid), nil
+		if ex.IsDeleted == models.BitIsSet {
+			rs := byRecurrence[recurrenceID]
+			rs.Exceptions = append(rs.Exceptions, ex)
+		} else if ex.IsDeleted == models.BitNone {
+			overrides[exEventID] = ex.Value
+		}
+	}
+	return sets, overrides, nil
 }
 
-func (r *repository) scanRecurrence(ctx context.Context, eventID int, rs *models.RecurrenceSet) (int, error) {
-	var recurrenceID int
-	err := r.client.Pool.QueryRow(ctx, `
-			SELECT
-				id,
-				interval,
-				until,
-				count,
-				week_start,
-				by_day,
-				by_month_day,
-				by_month,
-				period_day,
-				by_set_pos
-			FROM
-				caldav.recurrence
-			WHERE
-				event_component_id = $1
-		`, eventID).Scan(
-		&recurrenceID,
-		&rs.Interval, &rs.Until, &rs.Cnt, &rs.Wkst, &rs.Weekdays,
-		&rs.Monthdays, &rs.Months, &rs.PeriodDay, &rs.BySetPos,
-	)
+func (r *repository) scanAttendees(ctx context.Context, eventIDs []int) (map[int][]models.Attendee, error) {
+	rows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			event_component_id,
+			email,
+			common_name,
+			directory_entry_ref,
+			language,
+			user_type,
+			sent_by,
+			delegated_from,
+			delegated_to,
+			rsvp,
+			participation_role,
+			participation_status
+		FROM caldav.attendee
+		WHERE event_component_id = ANY($1)
+		ORDER BY id
+	`, eventIDs)
 	if err != nil {
-		if !r.client.IsNoRows(err) {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.GetCalendars", logger.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	attendees := make(map[int][]models.Attendee)
+	for rows.Next() {
+		var a models.Attendee
+		var eventID int
+
+		if err := rows.Scan(
+			&eventID,
+			&a.Email, &a.CommonName, &a.DirectoryEntryRef, &a.Language, &a.UserType,
+			&a.SentBy, &a.DelegatedFrom, &a.DelegatedTo, &a.RSVP,
+			&a.ParticipationRole, &a.ParticipationStatus,
+		); err != nil {
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.GetCalendars", logger.Err(err))
+			return nil, err
+		}
+		attendees[eventID] = append(attendees[eventID], a)
+	}
+	return attendees, nil
+}
+
+func (r *repository) scanProperties(ctx context.Context, eventIDs []int) (map[int][]models.Property, error) {
+	rows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			event_component_id,
+			name,
+			params,
+			value
+		FROM caldav.property
+		WHERE event_component_id = ANY($1)
+		ORDER BY id
+	`, eventIDs)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.GetCalendars", logger.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	props := make(map[int][]models.Property)
+	for rows.Next() {
+		var prop models.Property
+		var eventID int
+
+		if err := rows.Scan(&eventID, &prop.Name, &prop.Params, &prop.Value); err != nil {
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.GetCalendars", logger.Err(err))
+			return nil, err
+		}
+		props[eventID] = append(props[eventID], prop)
+	}
+	return props, nil
+}
+
+func (r *repository) scanRelations(ctx context.Context, eventIDs []int) (map[int][]models.Relation, error) {
+	rows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			event_component_id,
+			related_uid,
+			relationship_type
+		FROM caldav.relation
+		WHERE event_component_id = ANY($1)
+		ORDER BY id
+	`, eventIDs)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.GetCalendars", logger.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	relations := make(map[int][]models.Relation)
+	for rows.Next() {
+		var rel models.Relation
+		var eventID int
+
+		if err := rows.Scan(&eventID, &rel.RelatedUID, &rel.RelationshipType); err != nil {
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.GetCalendars", logger.Err(err))
+			return nil, err
+		}
+		relations[eventID] = append(relations[eventID], rel)
+	}
+	return relations, nil
+}
+
+func (r *repository) scanAlarms(ctx context.Context, eventIDs []int) (map[int][]models.Alarm, error) {
+	rows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			event_component_id,
+			action,
+			trigger,
+			trigger_related,
+			summary,
+			description,
+			duration,
+			repeat
+		FROM caldav.alarm
+		WHERE event_component_id = ANY($1)
+		ORDER BY id
+	`, eventIDs)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.GetCalendars", logger.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	alarms := make(map[int][]models.Alarm)
+	for rows.Next() {
+		var a models.Alarm
+		var eventID int
+
+		if err := rows.Scan(
+			&eventID,
+			&a.Action, &a.Trigger, &a.TriggerRelated,
+			&a.Summary, &a.Description, &a.Duration, &a.Repeat,
+		); err != nil {
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.GetCalendars", logger.Err(err))
+			return nil, err
+		}
+		alarms[eventID] = append(alarms[eventID], a)
+	}
+	return alarms, nil
+}
+
+func (r *repository) scanAttachments(ctx context.Context, eventIDs []int) (map[int][]models.Attachment, error) {
+	rows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			event_component_id,
+			managed_id,
+			media_type,
+			filename,
+			external_url,
+			CASE WHEN managed_id IS NULL THEN content END,
+			octet_length(content)
+		FROM caldav.attachment
+		WHERE event_component_id = ANY($1)
+		ORDER BY id
+	`, eventIDs)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.GetCalendars", logger.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := make(map[int][]models.Attachment)
+	for rows.Next() {
+		var a models.Attachment
+		var eventID int
+
+		if err := rows.Scan(
+			&eventID, &a.ManagedID, &a.MediaType, &a.Filename, &a.ExternalURL, &a.Content, &a.Size,
+		); err != nil {
 			err = r.client.ToPgErr(err)
-			r.logger.Error("postgres.GetCalendar", logger.Err(err))
-			return 0, err
+			r.logger.Error("postgres.GetCalendars", logger.Err(err))
+			return nil, err
 		}
+		attachments[eventID] = append(attachments[eventID], a)
 	}
-	return recurrenceID, nil
+	return attachments, nil
 }
 
 func (r *repository) FindCalendarObjects(
 	ctx context.Context,
+	userID string,
 	folderID int,
 	propFilter []string,
 ) ([]caldav.CalendarObject, error) {
@@ -561,13 +1416,14 @@ func (r *repository) FindCalendarObjects(
 
 	rows, err := r.client.Pool.Query(ctx, `
 		SELECT
-			uid,
-			etag,
-			modified_at,
-			size
-		FROM caldav.calendar_file
-		WHERE calendar_folder_id = $1
-	`, &folderID)
+			c.name,
+			c.etag,
+			c.modified_at,
+			c.size
+		FROM caldav.calendar_file c
+			JOIN caldav.access a ON a.calendar_folder_id = c.calendar_folder_id
+		WHERE c.calendar_folder_id = $1 AND a.user_id = $2 AND a.read = B'1'
+	`, &folderID, userID)
 	if err != nil {
 		err = r.client.ToPgErr(err)
 		r.logger.Error("postgres.FindCalendarObjects", logger.Err(err))
@@ -594,3 +1450,808 @@ func (r *repository) FindCalendarObjects(
 
 	return result, nil
 }
+
+// FindCalendarObjectsByName returns the named objects of the folder, names of
+// missing objects are left out.
+func (r *repository) FindCalendarObjectsByName(
+	ctx context.Context,
+	userID string,
+	folderID int,
+	names []string,
+) ([]caldav.CalendarObject, error) {
+	r.logger.Debug("postgres.FindCalendarObjectsByName")
+
+	var result []caldav.CalendarObject
+
+	rows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			c.name,
+			c.etag,
+			c.modified_at,
+			c.size
+		FROM caldav.calendar_file c
+			JOIN caldav.access a ON a.calendar_folder_id = c.calendar_folder_id
+		WHERE c.calendar_folder_id = $1 AND c.name = ANY($2) AND a.user_id = $3 AND a.read = B'1'
+	`, folderID, names, userID)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.FindCalendarObjectsByName", logger.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var obj caldav.CalendarObject
+
+		err = rows.Scan(
+			&obj.Path,
+			&obj.ETag,
+			&obj.ModTime,
+			&obj.ContentLength,
+		)
+		if err != nil {
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.FindCalendarObjectsByName", logger.Err(err))
+			return nil, err
+		}
+
+		result = append(result, obj)
+	}
+
+	return result, nil
+}
+
+// compFilterCondition narrows calendar files down to the ones having a component
+// of the requested type which overlaps the requested time range. Recurring
+// masters are kept as candidates until their UNTIL, since their instances are
+// only known after expansion. Tasks end at their DUE, other components without
+// an end are given a day, which covers DATE values.
+const compFilterCondition = `
+			AND EXISTS (
+				SELECT 1
+				FROM caldav.event_component e
+					LEFT JOIN caldav.recurrence r ON r.event_component_id = e.id
+				WHERE e.calendar_file_uid = c.uid
+					AND ($%[1]d::caldav.calendar_type IS NULL OR e.component_type = $%[1]d::caldav.calendar_type)
+					AND ($%[3]d::timestamp IS NULL OR e.start_date IS NULL OR e.start_date < $%[3]d::timestamp)
+					AND ($%[2]d::timestamp IS NULL OR e.start_date IS NULL
+						OR r.id IS NOT NULL AND (r.until IS NULL OR r.until >= $%[2]d::timestamp::date)
+						OR COALESCE(e.end_date, e.todo_due, e.start_date + INTERVAL '1 day') >= $%[2]d::timestamp)
+			)`
+
+func (r *repository) QueryCalendarObjects(
+	ctx context.Context,
+	userID string,
+	folderID int,
+	filter *caldav.CompFilter,
+) ([]caldav.CalendarObject, error) {
+	r.logger.Debug("postgres.QueryCalendarObjects")
+
+	var result []caldav.CalendarObject
+
+	query := `
+		SELECT
+			c.name,
+			c.etag,
+			c.modified_at,
+			c.size
+		FROM caldav.calendar_file c
+			JOIN caldav.access a ON a.calendar_folder_id = c.calendar_folder_id
+		WHERE c.calendar_folder_id = $1 AND a.user_id = $2 AND a.read = B'1'`
+	args := []any{folderID, userID}
+
+	for _, cf := range models.ScanCompFilters(filter) {
+		args = append(args, cf.CompType, cf.Start, cf.End)
+		query += fmt.Sprintf(compFilterCondition, len(args)-2, len(args)-1, len(args))
+	}
+
+	rows, err := r.client.Pool.Query(ctx, query, args...)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.QueryCalendarObjects", logger.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var obj caldav.CalendarObject
+
+		err = rows.Scan(
+			&obj.Path,
+			&obj.ETag,
+			&obj.ModTime,
+			&obj.ContentLength,
+		)
+		if err != nil {
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.QueryCalendarObjects", logger.Err(err))
+			return nil, err
+		}
+
+		result = append(result, obj)
+	}
+
+	return result, nil
+}
+
+// FindBusyPeriods returns the busy time of the folder events overlapping
+// [start, end). Files with recurring events are returned by name instead, as
+// their instances are only known after expansion.
+func (r *repository) FindBusyPeriods(
+	ctx context.Context,
+	userID string,
+	folderID int,
+	start, end time.Time,
+) ([]models.BusyPeriod, []string, error) {
+	r.logger.Debug("postgres.FindBusyPeriods")
+
+	var periods []models.BusyPeriod
+	var recurring []string
+
+	rows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			c.name,
+			e.start_date,
+			e.end_date,
+			e.all_day,
+			e.status,
+			EXISTS (
+				SELECT 1
+				FROM caldav.event_component m
+					JOIN caldav.recurrence mr ON mr.event_component_id = m.id
+				WHERE m.calendar_file_uid = e.calendar_file_uid
+			) AS recurring
+		FROM caldav.event_component e
+			JOIN caldav.calendar_file c ON c.uid = e.calendar_file_uid
+			JOIN caldav.access a ON a.calendar_folder_id = c.calendar_folder_id
+			LEFT JOIN caldav.recurrence r ON r.event_component_id = e.id
+		WHERE c.calendar_folder_id = $1 AND a.user_id = $2 AND a.read = B'1'
+			AND e.component_type = 'VEVENT'
+			AND (e.event_transparency IS NULL OR e.event_transparency = B'1')
+			AND (e.status IS NULL OR e.status <> 'CANCELLED')
+			AND e.start_date < $4
+			AND (r.id IS NOT NULL AND (r.until IS NULL OR r.until >= $3::timestamp::date)
+				OR COALESCE(e.end_date, e.start_date + INTERVAL '1 day') > $3)
+	`, folderID, userID, start.UTC(), end.UTC())
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.FindBusyPeriods", logger.Err(err))
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		var startDate, endDate pgtype.Timestamp
+		var allDay, status pgtype.Text
+		var isRecurring bool
+
+		err = rows.Scan(&name, &startDate, &endDate, &allDay, &status, &isRecurring)
+		if err != nil {
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.FindBusyPeriods", logger.Err(err))
+			return nil, nil, err
+		}
+
+		if isRecurring {
+			if !seen[name] {
+				seen[name] = true
+				recurring = append(recurring, name)
+			}
+			continue
+		}
+
+		periodEnd := endDate.Time
+		if !endDate.Valid && allDay == models.BitIsSet {
+			periodEnd = startDate.Time.AddDate(0, 0, 1)
+		}
+		if period, ok := models.NewBusyPeriod(startDate.Time, periodEnd, status.String, ""); ok {
+			periods = append(periods, period)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.FindBusyPeriods", logger.Err(err))
+		return nil, nil, err
+	}
+
+	return periods, recurring, nil
+}
+
+// FindTaskTree returns the tasks of the folder arranged by their RELATED-TO
+// relations. Overridden instances of recurring tasks are left out, a task is
+// represented by its master component.
+func (r *repository) FindTaskTree(ctx context.Context, userID string, folderID int) ([]*models.Task, error) {
+	r.logger.Debug("postgres.FindTaskTree")
+
+	rows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			e.id,
+			c.ical_uid,
+			e.summary,
+			e.status,
+			e.todo_due,
+			e.todo_completed,
+			e.todo_percent_complete
+		FROM caldav.event_component e
+			JOIN caldav.calendar_file c ON c.uid = e.calendar_file_uid
+			JOIN caldav.access a ON a.calendar_folder_id = c.calendar_folder_id
+		WHERE c.calendar_folder_id = $1 AND a.user_id = $2 AND a.read = B'1'
+			AND e.component_type = 'VTODO'
+			AND NOT EXISTS (
+				SELECT 1
+				FROM caldav.recurrence_exception x
+				WHERE x.event_component_id = e.id AND x.deleted_recurrence = B'0'
+			)
+		ORDER BY e.id
+	`, folderID, userID)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.FindTaskTree", logger.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []*models.Task
+	var ids []int64
+	byID := make(map[int64]*models.Task)
+	for rows.Next() {
+		var t models.Task
+		var id int64
+
+		err = rows.Scan(&id, &t.UID, &t.Summary, &t.Status, &t.Due, &t.Completed, &t.PercentComplete)
+		if err != nil {
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.FindTaskTree", logger.Err(err))
+			return nil, err
+		}
+		tasks = append(tasks, &t)
+		ids = append(ids, id)
+		byID[id] = &t
+	}
+	if err = rows.Err(); err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.FindTaskTree", logger.Err(err))
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+
+	relRows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			event_component_id,
+			related_uid,
+			relationship_type
+		FROM caldav.relation
+		WHERE event_component_id = ANY($1)
+		ORDER BY id
+	`, ids)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.FindTaskTree", logger.Err(err))
+		return nil, err
+	}
+	defer relRows.Close()
+
+	for relRows.Next() {
+		var rel models.Relation
+		var id int64
+
+		if err = relRows.Scan(&id, &rel.RelatedUID, &rel.RelationshipType); err != nil {
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.FindTaskTree", logger.Err(err))
+			return nil, err
+		}
+		if t, ok := byID[id]; ok {
+			t.Relations = append(t.Relations, rel)
+		}
+	}
+	if err = relRows.Err(); err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.FindTaskTree", logger.Err(err))
+		return nil, err
+	}
+
+	return models.BuildTaskTree(tasks), nil
+}
+
+// MoveCalendarObject moves the object to another folder or name, replacing
+// the object found there. Its ETag is kept, the change log of both folders
+// follows the file.
+func (r *repository) MoveCalendarObject(
+	ctx context.Context,
+	userID string,
+	folderID int,
+	name string,
+	dstFolderID int,
+	dstName string,
+	ifMatch webdav.ConditionalMatch,
+) error {
+	r.logger.Debug("postgres.MoveCalendarObject")
+
+	var wantEtag string
+	var err error
+
+	if ifMatch.IsSet() && !ifMatch.IsWildcard() {
+		wantEtag, err = ifMatch.ETag()
+		if err != nil {
+			return webdav.NewHTTPError(http.StatusBadRequest, err)
+		}
+	}
+
+	tx, err := r.client.NewTx(ctx)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.MoveCalendarObject", logger.Err(err))
+		return err
+	}
+	defer func(tx *postgres.Tx, ctx context.Context) {
+		_ = tx.Rollback(ctx)
+	}(tx, ctx)
+
+	var uid, currentEtag string
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+			c.uid, c.etag
+		FROM
+			caldav.calendar_file c
+			JOIN caldav.access a ON a.calendar_folder_id = c.calendar_folder_id
+		WHERE
+			c.calendar_folder_id = $1 AND c.name = $2 AND a.user_id = $3 AND a.write = B'1'
+		FOR UPDATE OF c
+	`, folderID, name, userID).Scan(&uid, &currentEtag)
+	if err != nil {
+		if r.client.IsNoRows(err) {
+			return fmt.Errorf("%w: calendar object %s", postgres.ErrNotFound, name)
+		}
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.MoveCalendarObject", logger.Err(err))
+		return err
+	}
+
+	if wantEtag != "" && currentEtag != wantEtag {
+		return fmt.Errorf(
+			"%w: If-Match header is set and ETag does not match for calendar object %s",
+			postgres.ErrPreconditionFailed, name,
+		)
+	}
+
+	if err = r.checkWriteAccess(ctx, tx, userID, dstFolderID); err != nil {
+		return err
+	}
+
+	var supported bool
+
+	err = tx.QueryRow(ctx, `
+		SELECT NOT EXISTS (
+			SELECT 1
+			FROM caldav.event_component e, caldav.calendar_folder f
+			WHERE e.calendar_file_uid = $1 AND f.id = $2 AND NOT e.component_type = ANY (f.types)
+		)
+	`, uid, dstFolderID).Scan(&supported)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.MoveCalendarObject", logger.Err(err))
+		return err
+	}
+	if !supported {
+		return fmt.Errorf(
+			"%w: calendar %d does not support the components of %s",
+			postgres.ErrForbidden, dstFolderID, name,
+		)
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM caldav.calendar_file
+		WHERE calendar_folder_id = $1 AND name = $2 AND uid <> $3
+	`, dstFolderID, dstName, uid)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.MoveCalendarObject", logger.Err(err))
+		return err
+	}
+
+	// The change trigger logs the removal from the old folder and the
+	// addition to the new one.
+	_, err = tx.Exec(ctx, `
+		UPDATE caldav.calendar_file
+		SET calendar_folder_id = $2, name = $3, modified_at = $4
+		WHERE uid = $1
+	`, uid, dstFolderID, dstName, time.Now().UTC())
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.MoveCalendarObject", logger.Err(err))
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.MoveCalendarObject", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
+func (r *repository) DeleteCalendarObject(
+	ctx context.Context,
+	userID string,
+	folderID int,
+	name string,
+	ifMatch webdav.ConditionalMatch,
+) error {
+	r.logger.Debug("postgres.DeleteCalendarObject")
+
+	var wantEtag string
+	var err error
+
+	if ifMatch.IsSet() && !ifMatch.IsWildcard() {
+		wantEtag, err = ifMatch.ETag()
+		if err != nil {
+			return webdav.NewHTTPError(http.StatusBadRequest, err)
+		}
+	}
+
+	tx, err := r.client.NewTx(ctx)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.DeleteCalendarObject", logger.Err(err))
+		return err
+	}
+	defer func(tx *postgres.Tx, ctx context.Context) {
+		_ = tx.Rollback(ctx)
+	}(tx, ctx)
+
+	var uid, currentEtag string
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+			c.uid, c.etag
+		FROM
+			caldav.calendar_file c
+			JOIN caldav.access a ON a.calendar_folder_id = c.calendar_folder_id
+		WHERE
+			c.calendar_folder_id = $1 AND c.name = $2 AND a.user_id = $3 AND a.write = B'1'
+		FOR UPDATE OF c
+	`, folderID, name, userID).Scan(&uid, &currentEtag)
+	if err != nil {
+		if r.client.IsNoRows(err) {
+			return fmt.Errorf("%w: calendar object %s", postgres.ErrNotFound, name)
+		}
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.DeleteCalendarObject", logger.Err(err))
+		return err
+	}
+
+	if wantEtag != "" && currentEtag != wantEtag {
+		return fmt.Errorf(
+			"%w: If-Match header is set and ETag does not match for calendar object %s",
+			postgres.ErrPreconditionFailed, name,
+		)
+	}
+
+	// Properties, event components, recurrences and their exceptions
+	// are removed along with the file by ON DELETE CASCADE.
+	_, err = tx.Exec(ctx, `DELETE FROM caldav.calendar_file WHERE uid = $1`, uid)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.DeleteCalendarObject", logger.Err(err))
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.DeleteCalendarObject", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
+// GetCalendarRevision returns the folder revision, which every change of its
+// objects increments within the changing transaction.
+func (r *repository) GetCalendarRevision(ctx context.Context, userID string, folderID int) (int64, error) {
+	r.logger.Debug("postgres.GetCalendarRevision")
+
+	var revision int64
+
+	err := r.client.Pool.QueryRow(ctx, `
+		SELECT f.sync_revision
+		FROM caldav.calendar_folder f
+			JOIN caldav.access a ON a.calendar_folder_id = f.id
+		WHERE f.id = $1 AND a.user_id = $2 AND a.read = B'1'
+	`, folderID, userID).Scan(&revision)
+	if err != nil {
+		if r.client.IsNoRows(err) {
+			return 0, fmt.Errorf("%w: calendar %d", postgres.ErrNotFound, folderID)
+		}
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.GetCalendarRevision", logger.Err(err))
+		return 0, err
+	}
+
+	return revision, nil
+}
+
+// GetCalendarFolder returns the folder with the properties set by PROPPATCH.
+func (r *repository) GetCalendarFolder(ctx context.Context, userID string, folderID int) (*models.Folder, error) {
+	r.logger.Debug("postgres.GetCalendarFolder")
+
+	f := models.Folder{ID: folderID}
+
+	err := r.client.Pool.QueryRow(ctx, `
+		SELECT
+			f.name,
+			COALESCE(f.description, '') AS description,
+			f.sync_revision,
+			f.color,
+			f.sort_order,
+			f.timezone
+		FROM caldav.calendar_folder f
+			JOIN caldav.access a ON a.calendar_folder_id = f.id
+		WHERE f.id = $1 AND a.user_id = $2 AND a.read = B'1'
+	`, folderID, userID).Scan(&f.Name, &f.Description, &f.Revision, &f.Color, &f.Order, &f.Timezone)
+	if err != nil {
+		if r.client.IsNoRows(err) {
+			return nil, fmt.Errorf("%w: calendar %d", postgres.ErrNotFound, folderID)
+		}
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.GetCalendarFolder", logger.Err(err))
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// UpdateCalendar applies the patch to the properties of the folder. The sync
+// revision is bumped, so that clients comparing CTags notice the change.
+func (r *repository) UpdateCalendar(ctx context.Context, userID string, folderID int, patch *models.FolderPatch) error {
+	r.logger.Debug("postgres.UpdateCalendar")
+
+	args := []any{folderID, userID}
+	sets := []string{"sync_revision = sync_revision + 1"}
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if patch.Name != nil {
+		set("name", *patch.Name)
+	}
+	if patch.Description != nil {
+		set("description", *patch.Description)
+	}
+	if patch.Color != nil {
+		set("color", *patch.Color)
+	}
+	if patch.Order != nil {
+		set("sort_order", *patch.Order)
+	}
+	if patch.Timezone != nil {
+		set("timezone", *patch.Timezone)
+	}
+
+	tag, err := r.client.Pool.Exec(ctx, `
+		UPDATE caldav.calendar_folder f
+		SET `+strings.Join(sets, ", ")+`
+		WHERE f.id = $1 AND EXISTS (
+			SELECT 1
+			FROM caldav.access a
+			WHERE a.calendar_folder_id = f.id AND a.user_id = $2 AND a.write = B'1'
+		)
+	`, args...)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.UpdateCalendar", logger.Err(err))
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: calendar %d", postgres.ErrNotFound, folderID)
+	}
+	return nil
+}
+
+// SyncCalendarObjects returns the objects of the folder changed after the
+// revision, or every object of the folder if the revision is not set.
+func (r *repository) SyncCalendarObjects(
+	ctx context.Context,
+	userID string,
+	folderID int,
+	revision pgtype.Int8,
+	limit int,
+) (*models.SyncChanges, error) {
+	r.logger.Debug("postgres.SyncCalendarObjects")
+
+	var changes models.SyncChanges
+
+	// The folder revision is read first: everything committed later has a
+	// greater revision and is reported again on the next sync.
+	err := r.client.Pool.QueryRow(ctx, `
+		SELECT
+			f.sync_revision,
+			(SELECT MIN(ch.revision) FROM caldav.calendar_change ch WHERE ch.calendar_folder_id = f.id)
+		FROM caldav.calendar_folder f
+			JOIN caldav.access a ON a.calendar_folder_id = f.id
+		WHERE f.id = $1 AND a.user_id = $2 AND a.read = B'1'
+	`, folderID, userID).Scan(&changes.Revision, &changes.MinRevision)
+	if err != nil {
+		if r.client.IsNoRows(err) {
+			return nil, fmt.Errorf("%w: calendar %d", postgres.ErrNotFound, folderID)
+		}
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.SyncCalendarObjects", logger.Err(err))
+		return nil, err
+	}
+
+	if !revision.Valid {
+		changes.Changed, err = r.FindCalendarObjects(ctx, userID, folderID, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &changes, nil
+	}
+	if revision.Int64 >= changes.Revision {
+		return &changes, nil
+	}
+
+	var rowLimit pgtype.Int4
+	if limit > 0 {
+		rowLimit = pgtype.Int4{Int32: int32(limit) + 1, Valid: true}
+	}
+
+	rows, err := r.client.Pool.Query(ctx, `
+		SELECT
+			ch.name,
+			MAX(ch.revision) AS last_revision,
+			c.etag,
+			c.modified_at,
+			c.size
+		FROM caldav.calendar_change ch
+			LEFT JOIN caldav.calendar_file c ON c.calendar_folder_id = ch.calendar_folder_id
+				AND c.name = ch.name
+		WHERE ch.calendar_folder_id = $1 AND ch.revision > $2 AND ch.revision <= $3
+		GROUP BY ch.name, c.etag, c.modified_at, c.size
+		ORDER BY last_revision
+		LIMIT $4
+	`, folderID, revision.Int64, changes.Revision, rowLimit)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.SyncCalendarObjects", logger.Err(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var n int
+	var lastRevision int64
+	for rows.Next() {
+		if n++; limit > 0 && n > limit {
+			changes.Truncated = true
+			changes.Revision = lastRevision
+			break
+		}
+
+		var name string
+		var etag pgtype.Text
+		var modTime pgtype.Timestamp
+		var size pgtype.Int4
+
+		if err = rows.Scan(&name, &lastRevision, &etag, &modTime, &size); err != nil {
+			err = r.client.ToPgErr(err)
+			r.logger.Error("postgres.SyncCalendarObjects", logger.Err(err))
+			return nil, err
+		}
+
+		if !etag.Valid {
+			changes.Removed = append(changes.Removed, name)
+			continue
+		}
+		changes.Changed = append(changes.Changed, caldav.CalendarObject{
+			Path:          name,
+			ETag:          etag.String,
+			ModTime:       modTime.Time,
+			ContentLength: int64(size.Int32),
+		})
+	}
+	if err = rows.Err(); err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.SyncCalendarObjects", logger.Err(err))
+		return nil, err
+	}
+
+	return &changes, nil
+}
+
+func (r *repository) DeleteCalendar(ctx context.Context, userID string, folderID int) error {
+	r.logger.Debug("postgres.DeleteCalendar")
+
+	tx, err := r.client.NewTx(ctx)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.DeleteCalendar", logger.Err(err))
+		return err
+	}
+	defer func(tx *postgres.Tx, ctx context.Context) {
+		_ = tx.Rollback(ctx)
+	}(tx, ctx)
+
+	var isDefault bool
+	var foldersCnt int
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+			f.is_default
+		FROM
+			caldav.calendar_folder f
+			JOIN caldav.access a ON a.calendar_folder_id = f.id
+		WHERE
+			f.id = $1 AND a.user_id = $2 AND a.owner = B'1'
+		FOR UPDATE OF f
+	`, folderID, userID).Scan(&isDefault)
+	if err != nil {
+		if r.client.IsNoRows(err) {
+			return fmt.Errorf("%w: calendar %d", postgres.ErrNotFound, folderID)
+		}
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.DeleteCalendar", logger.Err(err))
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+			count(*)
+		FROM
+			caldav.access
+		WHERE
+			user_id = $1 AND owner = B'1'
+	`, userID).Scan(&foldersCnt)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.DeleteCalendar", logger.Err(err))
+		return err
+	}
+
+	if isDefault {
+		return fmt.Errorf("%w: default calendar %d can't be deleted", postgres.ErrForbidden, folderID)
+	}
+	if foldersCnt <= 1 {
+		return fmt.Errorf("%w: last calendar %d can't be deleted", postgres.ErrForbidden, folderID)
+	}
+
+	// Calendar files and everything under them are removed by ON DELETE CASCADE.
+	_, err = tx.Exec(ctx, `DELETE FROM caldav.calendar_folder WHERE id = $1`, folderID)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.DeleteCalendar", logger.Err(err))
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.DeleteCalendar", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
+// checkWriteAccess ensures that the user may write into the folder.
+func (r *repository) checkWriteAccess(ctx context.Context, tx *postgres.Tx, userID string, folderID int) error {
+	var canWrite bool
+
+	err := tx.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1
+			FROM caldav.access
+			WHERE calendar_folder_id = $1 AND user_id = $2 AND write = B'1'
+		)
+	`, folderID, userID).Scan(&canWrite)
+	if err != nil {
+		err = r.client.ToPgErr(err)
+		r.logger.Error("postgres.checkWriteAccess", logger.Err(err))
+		return err
+	}
+
+	if !canWrite {
+		return fmt.Errorf("%w: no write access to calendar %d", postgres.ErrForbidden, folderID)
+	}
+	return nil
+}
@@ -1,14 +1,85 @@
 package models
 
 import (
+	"strconv"
+
+	"github.com/emersion/go-ical"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// Alarm keeps TRIGGER and DURATION as given in iCalendar: a relative TRIGGER
+// is a duration, an absolute one a UTC date-time.
 type Alarm struct {
-	Action      pgtype.Text      `json:"action"`
-	Trigger     pgtype.Timestamp `json:"trigger"`
-	Summary     pgtype.Text      `json:"summary"`
-	Description pgtype.Text      `json:"description"`
-	Duration    pgtype.Uint32    `json:"duration"`
-	Repeat      pgtype.Uint32    `json:"repeat"`
+	Action         pgtype.Text   `json:"action"`
+	Trigger        pgtype.Text   `json:"trigger"`
+	TriggerRelated pgtype.Text   `json:"triggerRelated,omitempty"`
+	Summary        pgtype.Text   `json:"summary,omitempty"`
+	Description    pgtype.Text   `json:"description,omitempty"`
+	Duration       pgtype.Text   `json:"duration,omitempty"`
+	Repeat         pgtype.Uint32 `json:"repeat,omitempty"`
+}
+
+// ScanAlarms returns the VALARM components nested in the component. Alarms
+// without ACTION or TRIGGER are invalid and skipped.
+func ScanAlarms(event *ical.Component) []Alarm {
+	var alarms []Alarm
+	for _, child := range event.Children {
+		if child.Name != ical.CompAlarm {
+			continue
+		}
+		trigger := child.Props.Get(ical.PropTrigger)
+		a := Alarm{
+			Action:      textValue(child, ical.PropAction),
+			Summary:     textValue(child, ical.PropSummary),
+			Description: textValue(child, ical.PropDescription),
+			Duration:    textValue(child, ical.PropDuration),
+			Repeat:      intValue(child, ical.PropRepeat),
+		}
+		if !a.Action.Valid || trigger == nil {
+			continue
+		}
+		a.Trigger = pgtype.Text{String: trigger.Value, Valid: true}
+		a.TriggerRelated = paramValue(*trigger, ical.ParamRelated)
+		alarms = append(alarms, a)
+	}
+	return alarms
+}
+
+func (a *Alarm) ToDomain() *ical.Component {
+	alarm := ical.NewComponent(ical.CompAlarm)
+
+	alarm.Props.SetText(ical.PropAction, a.Action.String)
+
+	trigger := ical.NewProp(ical.PropTrigger)
+	trigger.Value = a.Trigger.String
+	if isDateTimeTrigger(trigger.Value) {
+		trigger.SetValueType(ical.ValueDateTime)
+	}
+	setParamValue(trigger, ical.ParamRelated, a.TriggerRelated)
+	alarm.Props.Set(trigger)
+
+	if a.Summary.Valid {
+		alarm.Props.SetText(ical.PropSummary, a.Summary.String)
+	}
+	if a.Description.Valid {
+		alarm.Props.SetText(ical.PropDescription, a.Description.String)
+	}
+	if a.Duration.Valid {
+		duration := ical.NewProp(ical.PropDuration)
+		duration.Value = a.Duration.String
+		alarm.Props.Set(duration)
+	}
+	if a.Repeat.Valid {
+		repeat := ical.NewProp(ical.PropRepeat)
+		repeat.SetValueType(ical.ValueInt)
+		repeat.Value = strconv.Itoa(int(a.Repeat.Uint32))
+		alarm.Props.Set(repeat)
+	}
+	return alarm
+}
+
+// isDateTimeTrigger reports whether the TRIGGER value is an absolute time:
+// durations always start with a sign or "P".
+func isDateTimeTrigger(value string) bool {
+	return value != "" && value[0] >= '0' && value[0] <= '9'
 }
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-ical"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Parameters of managed attachments (RFC 8607 §4).
+const (
+	ParamManagedID = "MANAGED-ID"
+	ParamFilename  = "FILENAME"
+	ParamSize      = "SIZE"
+)
+
+// Attachment is either an inline binary, an external URI or a managed
+// attachment. The content of managed attachments is served by the server
+// itself, so it is only loaded on demand and Size is given instead.
+type Attachment struct {
+	ManagedID   pgtype.Text `json:"managedId,omitempty"`
+	MediaType   pgtype.Text `json:"mediaType,omitempty"`
+	Filename    pgtype.Text `json:"filename,omitempty"`
+	ExternalURL pgtype.Text `json:"externalUrl,omitempty"`
+	Content     []byte      `json:"content,omitempty"`
+	Size        pgtype.Int4 `json:"size,omitempty"`
+}
+
+// ScanAttachments returns every ATTACH of the component. Managed attachments
+// given by reference keep their stored content.
+func ScanAttachments(event *ical.Component) []Attachment {
+	var attachments []Attachment
+	for _, prop := range event.Props.Values(ical.PropAttach) {
+		a := Attachment{
+			ManagedID: paramValue(prop, ParamManagedID),
+			MediaType: paramValue(prop, ical.ParamFormatType),
+			Filename:  paramValue(prop, ParamFilename),
+		}
+		if prop.ValueType() == ical.ValueBinary {
+			content, err := prop.Binary()
+			if err != nil {
+				continue
+			}
+			a.Content = content
+		} else if !a.ManagedID.Valid {
+			a.ExternalURL = pgtype.Text{String: prop.Value, Valid: true}
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments
+}
+
+// ToDomain returns the ATTACH property. The URL of managed attachments depends
+// on the request and is left for the server to fill in.
+func (a *Attachment) ToDomain() *ical.Prop {
+	prop := ical.NewProp(ical.PropAttach)
+
+	setParamValue(prop, ical.ParamFormatType, a.MediaType)
+	setParamValue(prop, ParamManagedID, a.ManagedID)
+	setParamValue(prop, ParamFilename, a.Filename)
+
+	switch {
+	case a.Content != nil:
+		prop.SetBinary(a.Content)
+	case a.ManagedID.Valid:
+		if a.Size.Valid {
+			prop.Params.Set(ParamSize, strconv.Itoa(int(a.Size.Int32)))
+		}
+	default:
+		prop.Value = a.ExternalURL.String
+	}
+	return prop
+}
+
+// FindInstances returns the components of the calendar matching the
+// recurrence ids of a "rid" query parameter (RFC 8607 §3.4): "M" is the master
+// component, other values are RECURRENCE-ID in UTC. Without rids every
+// component is returned. It returns false if one of rids matches nothing.
+func FindInstances(cal *ical.Calendar, rids []string) ([]*ical.Component, bool) {
+	locs, children := splitTimezones(cal)
+	if len(rids) == 0 {
+		return children, true
+	}
+
+	var instances []*ical.Component
+	for _, rid := range rids {
+		found := false
+		for _, child := range children {
+			if matchesRecurrenceID(child, locs, rid) {
+				instances = append(instances, child)
+				found = true
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return instances, true
+}
+
+func matchesRecurrenceID(comp *ical.Component, locs Locations, rid string) bool {
+	if comp.Props.Get(ical.PropRecurrenceID) == nil {
+		return strings.EqualFold(rid, "M")
+	}
+	value, _ := zonedTimeValue(comp, ical.PropRecurrenceID, locs)
+	if !value.Valid {
+		return false
+	}
+	if len(rid) == len(dateFormat) {
+		return value.Time.Format(dateFormat) == rid
+	}
+	return value.Time.UTC().Format(datetimeUTCFormat) == rid
+}
+
+// ReplaceManagedAttachment replaces the ATTACH properties of the component
+// having the MANAGED-ID with prop, or removes them if prop is nil. It returns
+// false if the component has no such attachment.
+func ReplaceManagedAttachment(comp *ical.Component, managedID string, prop *ical.Prop) bool {
+	found := false
+	var props []ical.Prop
+	for _, attach := range comp.Props.Values(ical.PropAttach) {
+		if attach.Params.Get(ParamManagedID) != managedID {
+			props = append(props, attach)
+			continue
+		}
+		found = true
+		if prop != nil {
+			props = append(props, *prop)
+		}
+	}
+	if len(props) == 0 {
+		comp.Props.Del(ical.PropAttach)
+	} else {
+		comp.Props[ical.PropAttach] = props
+	}
+	return found
+}
+
+// HasManagedAttachments reports whether the calendar holds managed
+// attachments, whose ATTACH values depend on the server they are served from.
+func HasManagedAttachments(cal *ical.Calendar) bool {
+	for _, child := range cal.Children {
+		for _, prop := range child.Props.Values(ical.PropAttach) {
+			if prop.Params.Get(ParamManagedID) != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
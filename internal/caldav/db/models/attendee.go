@@ -0,0 +1,112 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/emersion/go-ical"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const mailtoScheme = "mailto:"
+
+type Attendee struct {
+	Email               pgtype.Text `json:"email"`
+	CommonName          pgtype.Text `json:"commonName,omitempty"`
+	DirectoryEntryRef   pgtype.Text `json:"directoryEntryRef,omitempty"`
+	Language            pgtype.Text `json:"language,omitempty"`
+	UserType            pgtype.Text `json:"userType,omitempty"`
+	SentBy              pgtype.Text `json:"sentBy,omitempty"`
+	DelegatedFrom       pgtype.Text `json:"delegatedFrom,omitempty"`
+	DelegatedTo         pgtype.Text `json:"delegatedTo,omitempty"`
+	RSVP                pgtype.Text `json:"rsvp,omitempty"`
+	ParticipationRole   pgtype.Text `json:"participationRole,omitempty"`
+	ParticipationStatus pgtype.Text `json:"participationStatus,omitempty"`
+}
+
+// ScanAttendees returns every ATTENDEE of the component. Addresses using the
+// mailto scheme are stored as bare emails.
+func ScanAttendees(event *ical.Component) []Attendee {
+	var attendees []Attendee
+	for _, prop := range event.Props.Values(ical.PropAttendee) {
+		a := Attendee{
+			Email:               pgtype.Text{String: prop.Value, Valid: true},
+			CommonName:          paramValue(prop, ical.ParamCommonName),
+			DirectoryEntryRef:   paramValue(prop, ical.ParamDir),
+			Language:            paramValue(prop, ical.ParamLanguage),
+			UserType:            paramValue(prop, ical.ParamCalendarUserType),
+			SentBy:              paramValue(prop, ical.ParamSentBy),
+			DelegatedFrom:       paramValues(prop, ical.ParamDelegatedFrom),
+			DelegatedTo:         paramValues(prop, ical.ParamDelegatedTo),
+			ParticipationRole:   paramValue(prop, ical.ParamRole),
+			ParticipationStatus: paramValue(prop, ical.ParamParticipationStatus),
+		}
+		if len(prop.Value) >= len(mailtoScheme) && strings.EqualFold(prop.Value[:len(mailtoScheme)], mailtoScheme) {
+			a.Email.String = prop.Value[len(mailtoScheme):]
+		}
+
+		switch strings.ToUpper(prop.Params.Get(ical.ParamRSVP)) {
+		case "TRUE":
+			a.RSVP = BitIsSet
+		case "FALSE":
+			a.RSVP = BitNone
+		}
+		attendees = append(attendees, a)
+	}
+	return attendees
+}
+
+func (a *Attendee) ToDomain() *ical.Prop {
+	prop := ical.NewProp(ical.PropAttendee)
+	prop.SetValueType(ical.ValueCalendarAddress)
+	prop.Value = a.Email.String
+	if !strings.Contains(prop.Value, ":") {
+		prop.Value = mailtoScheme + prop.Value
+	}
+
+	setParamValue(prop, ical.ParamCommonName, a.CommonName)
+	setParamValue(prop, ical.ParamDir, a.DirectoryEntryRef)
+	setParamValue(prop, ical.ParamLanguage, a.Language)
+	setParamValue(prop, ical.ParamCalendarUserType, a.UserType)
+	setParamValue(prop, ical.ParamSentBy, a.SentBy)
+	setParamValues(prop, ical.ParamDelegatedFrom, a.DelegatedFrom)
+	setParamValues(prop, ical.ParamDelegatedTo, a.DelegatedTo)
+	setParamValue(prop, ical.ParamRole, a.ParticipationRole)
+	setParamValue(prop, ical.ParamParticipationStatus, a.ParticipationStatus)
+
+	if a.RSVP == BitIsSet {
+		prop.Params.Set(ical.ParamRSVP, "TRUE")
+	} else if a.RSVP == BitNone {
+		prop.Params.Set(ical.ParamRSVP, "FALSE")
+	}
+	return prop
+}
+
+func paramValue(prop ical.Prop, paramName string) pgtype.Text {
+	value := prop.Params.Get(paramName)
+	if value == "" {
+		return pgtype.Text{Valid: false}
+	}
+	return pgtype.Text{String: value, Valid: true}
+}
+
+// paramValues joins the values of a multi-valued parameter with commas, the
+// way they are written in iCalendar.
+func paramValues(prop ical.Prop, paramName string) pgtype.Text {
+	values := prop.Params[paramName]
+	if len(values) == 0 {
+		return pgtype.Text{Valid: false}
+	}
+	return pgtype.Text{String: strings.Join(values, ","), Valid: true}
+}
+
+func setParamValue(prop *ical.Prop, paramName string, text pgtype.Text) {
+	if text.Valid {
+		prop.Params.Set(paramName, text.String)
+	}
+}
+
+func setParamValues(prop *ical.Prop, paramName string, text pgtype.Text) {
+	if text.Valid {
+		prop.Params[paramName] = strings.Split(text.String, ",")
+	}
+}
@@ -1,16 +1,18 @@
 package models
 
 import (
+	"github.com/ceres919/go-webdav/caldav"
 	"github.com/emersion/go-ical"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Calendar struct {
-	Version string      `json:"version"`
-	Product string      `json:"product"`
-	Scale   pgtype.Text `json:"scale,omitempty"`
-	Method  pgtype.Text `json:"method,omitempty"`
-	Events  []Event     `json:"events"`
+	Version   string      `json:"version"`
+	Product   string      `json:"product"`
+	Scale     pgtype.Text `json:"scale,omitempty"`
+	Method    pgtype.Text `json:"method,omitempty"`
+	Events    []Event     `json:"events"`
+	Timezones []Timezone  `json:"timezones,omitempty"`
 }
 
 func (c *Calendar) ToDomain(uid string) *ical.Calendar {
@@ -24,10 +26,43 @@ func (c *Calendar) ToDomain(uid string) *ical.Calendar {
 	if c.Method.Valid {
 		cal.Props.SetText(ical.PropMethod, c.Method.String)
 	}
-	cal.Children = make([]*ical.Component, 0, len(c.Events))
+	cal.Children = make([]*ical.Component, 0, len(c.Timezones)+len(c.Events))
+	for _, tz := range c.Timezones {
+		if comp := tz.ToDomain(); comp != nil {
+			cal.Children = append(cal.Children, comp)
+		}
+	}
+	locs := NewLocations(cal.Children)
 	for _, event := range c.Events {
-		cal.Children = append(cal.Children, event.ToDomain(uid))
+		cal.Children = append(cal.Children, event.ToDomain(uid, locs))
 	}
 
 	return cal
 }
+
+// CalendarObjectWrite is a calendar object to create or update, along with
+// others in a single transaction. Data is kept in raw storage mode only.
+// Replace removes the object stored under the name if it holds another UID,
+// as the overwriting COPY does, instead of failing. Series names the series
+// split off the object along with it, the ones split off it before at other
+// instances are removed. ThisAndFuture marks the object as such a series.
+// Source is the object copied by the write, which fails unless the source
+// still is at that version.
+type CalendarObjectWrite struct {
+	UID           string
+	EventType     string
+	Object        *caldav.CalendarObject
+	Data          []byte
+	Opts          *caldav.PutCalendarObjectOptions
+	Replace       bool
+	Series        []string
+	ThisAndFuture bool
+	Source        *ObjectVersion
+}
+
+// ObjectVersion is a calendar object of a folder at the version of its ETag.
+type ObjectVersion struct {
+	FolderID int
+	Name     string
+	ETag     string
+}
@@ -9,7 +9,7 @@ import (
 )
 
 type Event struct {
-	CompTypeBit         pgtype.Text                       `json:"compTypeBit,omitempty"`
+	CompType            pgtype.Text                       `json:"compType,omitempty"`
 	Transparent         pgtype.Text                       `json:"transparent,omitempty"`
 	AllDay              pgtype.Text                       `json:"allDay,omitempty"`
 	Summary             pgtype.Text                       `json:"summary,omitempty"`
@@ -25,24 +25,33 @@ type Event struct {
 	LastModified        pgtype.Timestamp                  `json:"lastModified,omitempty"`
 	Start               pgtype.Timestamp                  `json:"start,omitempty"`
 	End                 pgtype.Timestamp                  `json:"end,omitempty"`
+	StartTZID           pgtype.Text                       `json:"startTzid,omitempty"`
+	EndTZID             pgtype.Text                       `json:"endTzid,omitempty"`
 	Duration            pgtype.Uint32                     `json:"duration,omitempty"`
 	Priority            pgtype.Uint32                     `json:"priority,omitempty"`
 	Sequence            pgtype.Uint32                     `json:"sequence,omitempty"`
-	Completed           pgtype.Uint32                     `json:"completed,omitempty"`
+	Due                 pgtype.Timestamp                  `json:"due,omitempty"`
+	DueTZID             pgtype.Text                       `json:"dueTzid,omitempty"`
+	Completed           pgtype.Timestamp                  `json:"completed,omitempty"`
 	PerCompleted        pgtype.Uint32                     `json:"perCompleted,omitempty"`
 	RecurrenceSet       *RecurrenceSet                    `json:"recurrenceSet,omitempty"`
 	Properties          map[string]map[ical.ValueType]any `json:"props,omitempty"`
-	NotDeletedException string                            `json:"notDeletedException,omitempty"`
-	Alarm               *Alarm                            `json:"alarm,omitempty"`
+	NotDeletedException pgtype.Timestamp                  `json:"notDeletedException,omitempty"`
+	Alarms              []Alarm                           `json:"alarms,omitempty"`
+	Attachments         []Attachment                      `json:"attachments,omitempty"`
+	JournalDescriptions []string                          `json:"journalDescriptions,omitempty"`
+	Attendees           []Attendee                        `json:"attendees,omitempty"`
+	Relations           []Relation                        `json:"relations,omitempty"`
+	ExtraProps          []Property                        `json:"extraProps,omitempty"`
 }
 
-func ScanEvent(event *ical.Component) *Event {
+func ScanEvent(event *ical.Component, locs Locations) *Event {
 	if event == nil {
 		return nil
 	}
 
 	e := Event{
-		CompTypeBit:  pgtype.Text{Valid: false},
+		CompType:     pgtype.Text{Valid: false},
 		Transparent:  pgtype.Text{Valid: false},
 		AllDay:       pgtype.Text{String: "0", Valid: true},
 		Summary:      textValue(event, ical.PropSummary),
@@ -56,22 +65,24 @@ func ScanEvent(event *ical.Component) *Event {
 		Timestamp:    timeValue(event, ical.PropDateTimeStamp),
 		Created:      timeValue(event, ical.PropCreated),
 		LastModified: timeValue(event, ical.PropLastModified),
-		Start:        timeValue(event, ical.PropDateTimeStart),
-		End:          timeValue(event, ical.PropDateTimeEnd),
 		Duration:     intValue(event, ical.PropDuration),
 		Priority:     intValue(event, ical.PropPriority),
 		Sequence:     intValue(event, ical.PropSequence),
-		Completed:    intValue(event, ical.PropCompleted),
+		Completed:    timeValue(event, ical.PropCompleted),
 		PerCompleted: intValue(event, ical.PropPercentComplete),
 		Properties:   make(map[string]map[ical.ValueType]any),
+		Attendees:    ScanAttendees(event),
+		Alarms:       ScanAlarms(event),
+		Attachments:  ScanAttachments(event),
+		Relations:    ScanRelations(event),
+		ExtraProps:   ScanProperties(event),
 	}
 
-	switch event.Name {
-	case ical.CompEvent:
-		e.CompTypeBit = BitIsSet
-	case ical.CompToDo:
-		e.CompTypeBit = BitNone
-	}
+	e.Start, e.StartTZID = zonedTimeValue(event, ical.PropDateTimeStart, locs)
+	e.End, e.EndTZID = zonedTimeValue(event, ical.PropDateTimeEnd, locs)
+	e.Due, e.DueTZID = zonedTimeValue(event, ical.PropDue, locs)
+
+	e.CompType = ComponentType(event.Name)
 
 	transparent := textValue(event, ical.PropTransparency)
 	if transparent.Valid {
@@ -83,6 +94,20 @@ func ScanEvent(event *ical.Component) *Event {
 		}
 	}
 
+	// VJOURNAL may have several DESCRIPTION, the first one is kept in
+	// Description
+	if descriptions := event.Props.Values(ical.PropDescription); len(descriptions) > 1 {
+		for _, prop := range descriptions[1:] {
+			text, err := prop.Text()
+			if err != nil {
+				continue
+			}
+			e.JournalDescriptions = append(e.JournalDescriptions, text)
+		}
+	}
+
+	// X- properties are kept in ExtraProps as well, Properties only types their
+	// first value for search
 	for k, v := range event.Props {
 		if strings.HasPrefix(k, "X-") {
 			e.Properties[v[0].Name] = toJSONFormat(v[0].Value, v[0].ValueType())
@@ -96,13 +121,20 @@ func ScanEvent(event *ical.Component) *Event {
 	return &e
 }
 
-func (c *Event) ToDomain(uid string) *ical.Component {
+// ComponentType maps a component name to the event_component.component_type value.
+func ComponentType(name string) pgtype.Text {
+	switch name {
+	case ical.CompEvent, ical.CompToDo, ical.CompJournal:
+		return pgtype.Text{String: name, Valid: true}
+	}
+	return pgtype.Text{Valid: false}
+}
+
+func (c *Event) ToDomain(uid string, locs Locations) *ical.Component {
 	calEvent := ical.NewEvent()
 
-	if c.CompTypeBit == BitIsSet {
-		calEvent.Name = ical.CompEvent
-	} else if c.CompTypeBit == BitNone {
-		calEvent.Name = ical.CompToDo
+	if c.CompType.Valid {
+		calEvent.Name = c.CompType.String
 	}
 
 	setTextValue(calEvent, ical.PropSummary, c.Summary)
@@ -117,10 +149,11 @@ func (c *Event) ToDomain(uid string) *ical.Component {
 	setIntValue(calEvent, ical.PropSequence, c.Sequence)
 	setTextValue(calEvent, ical.PropStatus, c.Status)
 	setTextValue(calEvent, ical.PropCategories, c.Categories)
-	setIntValue(calEvent, ical.PropCompleted, c.Completed)
+	setTimestampValue(calEvent, ical.PropCompleted, c.Completed)
 	setIntValue(calEvent, ical.PropPercentComplete, c.PerCompleted)
-	setTimestampValue(calEvent, ical.PropDateTimeStart, c.Start)
-	setTimestampValue(calEvent, ical.PropDateTimeEnd, c.End)
+	setZonedTimestampValue(calEvent, ical.PropDateTimeStart, c.Start, c.StartTZID, locs)
+	setZonedTimestampValue(calEvent, ical.PropDateTimeEnd, c.End, c.EndTZID, locs)
+	setZonedTimestampValue(calEvent, ical.PropDue, c.Due, c.DueTZID, locs)
 	setTimestampValue(calEvent, ical.PropCreated, c.Created)
 	setTimestampValue(calEvent, ical.PropDateTimeStamp, c.Timestamp)
 	setTimestampValue(calEvent, ical.PropLastModified, c.LastModified)
@@ -131,29 +164,53 @@ func (c *Event) ToDomain(uid string) *ical.Component {
 		setTextValue(calEvent, ical.PropTransparency, pgtype.Text{String: "TRANSPARENT", Valid: true})
 	}
 
-	for name, valueType := range c.Properties {
-		custom := ical.NewProp(name)
-		fromJSONFormat(custom, valueType)
-		calEvent.Props.Set(custom)
+	for i := range c.Attendees {
+		calEvent.Props.Add(c.Attendees[i].ToDomain())
+	}
+	for _, text := range c.JournalDescriptions {
+		description := ical.NewProp(ical.PropDescription)
+		description.SetText(text)
+		calEvent.Props.Add(description)
+	}
+	for i := range c.Relations {
+		calEvent.Props.Add(c.Relations[i].ToDomain())
+	}
+	for i := range c.Attachments {
+		calEvent.Props.Add(c.Attachments[i].ToDomain())
+	}
+	for i := range c.Alarms {
+		calEvent.Children = append(calEvent.Children, c.Alarms[i].ToDomain())
 	}
 
-	rs, exString := c.RecurrenceSet.ToDomain()
-	if rs != nil {
-		rs.Dtstart = c.Start.Time.UTC()
-		calEvent.Props.SetRecurrenceRule(rs)
+	for i := range c.ExtraProps {
+		calEvent.Props.Add(c.ExtraProps[i].ToDomain())
+	}
+	// Components stored before ExtraProps only have their X- properties typed
+	if len(c.ExtraProps) == 0 {
+		for name, valueType := range c.Properties {
+			custom := ical.NewProp(name)
+			fromJSONFormat(custom, valueType)
+			calEvent.Props.Set(custom)
+		}
+	}
+
+	rule, exString := c.RecurrenceSet.ToDomain(locs.In(c.Start, c.StartTZID))
+	if rule != nil {
+		calEvent.Props.Set(rule)
+	}
+	for _, rdate := range RecurrenceDatesToDomain(c.RecurrenceSet.Dates, locs) {
+		calEvent.Props.Add(rdate)
 	}
 	if exString != "" {
 		exProp := ical.NewProp(ical.PropExceptionDates)
 		exProp.SetValueType(ical.ValueDateTime)
+		if c.StartTZID.Valid {
+			exProp.Params.Set(ical.ParamTimezoneID, c.StartTZID.String)
+		}
 		exProp.Value = exString
 		calEvent.Props.Set(exProp)
 	}
-	if c.NotDeletedException != "" {
-		recurrenceIDProp := ical.NewProp(ical.PropRecurrenceID)
-		recurrenceIDProp.SetValueType(ical.ValueDateTime)
-		recurrenceIDProp.Value = c.NotDeletedException
-		calEvent.Props.Set(recurrenceIDProp)
-	}
+	setZonedTimestampValue(calEvent, ical.PropRecurrenceID, c.NotDeletedException, c.StartTZID, locs)
 
 	return calEvent.Component
 }
@@ -0,0 +1,298 @@
+package models
+
+import (
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/teambition/rrule-go"
+)
+
+// ExpandCalendar replaces every recurring component of the calendar with its
+// instances overlapping [start, end), as required by CALDAV:expand. Instances
+// are given in UTC and VTIMEZONE components are dropped (RFC 4791 §9.6.5).
+func ExpandCalendar(cal *ical.Calendar, start, end time.Time) *ical.Calendar {
+	locs, children := splitTimezones(cal)
+	out := &ical.Calendar{Component: &ical.Component{Name: cal.Name, Props: cal.Props}}
+
+	for _, group := range groupRecurrences(children) {
+		overridden := make(map[int64]bool, len(group.overrides))
+		for _, override := range group.overrides {
+			rid, _ := zonedTimeValue(override, ical.PropRecurrenceID, locs)
+			overridden[rid.Time.Unix()] = true
+			if overlaps(override, locs, start, end) {
+				out.Children = append(out.Children, utcComponent(override, locs))
+			}
+		}
+
+		if group.master == nil {
+			continue
+		}
+		set, periods := recurrenceSet(group.master, locs)
+		if set == nil {
+			if overlaps(group.master, locs, start, end) {
+				out.Children = append(out.Children, utcComponent(group.master, locs))
+			}
+			continue
+		}
+
+		duration := componentDuration(group.master, locs)
+		longest := duration
+		for _, period := range periods {
+			longest = max(longest, period)
+		}
+		for _, instance := range instancesBetween(set, start.Add(-longest), end) {
+			instanceDuration, isPeriod := periods[instance.Unix()]
+			if !isPeriod {
+				instanceDuration = duration
+			}
+			if overridden[instance.Unix()] || !overlapsRange(instance, instanceDuration, start, end) {
+				continue
+			}
+			comp := instanceComponent(group.master, locs, instance, instanceDuration)
+			if isPeriod && comp.Props.Get(ical.PropDue) == nil {
+				comp.Props.Del(ical.PropDuration)
+				setInstanceTime(comp, ical.PropDateTimeEnd, instance.Add(instanceDuration), false)
+			}
+			out.Children = append(out.Children, comp)
+		}
+	}
+	return out
+}
+
+// LimitRecurrenceSet keeps recurring masters intact but drops the overridden
+// instances which do not overlap [start, end), as required by
+// CALDAV:limit-recurrence-set.
+func LimitRecurrenceSet(cal *ical.Calendar, start, end time.Time) *ical.Calendar {
+	locs, _ := splitTimezones(cal)
+	out := &ical.Calendar{Component: &ical.Component{Name: cal.Name, Props: cal.Props}}
+
+	for _, child := range cal.Children {
+		if child.Props.Get(ical.PropRecurrenceID) != nil && !overlaps(child, locs, start, end) {
+			continue
+		}
+		out.Children = append(out.Children, child)
+	}
+	return out
+}
+
+// MatchTimeRange reports whether a component of the calendar with the name has
+// an instance overlapping [start, end). Components without DTSTART never match.
+func MatchTimeRange(cal *ical.Calendar, name string, start, end time.Time) bool {
+	for _, child := range ExpandCalendar(cal, start, end).Children {
+		if _, ok := componentStart(child, nil); ok && child.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+type recurrenceGroup struct {
+	master    *ical.Component
+	overrides []*ical.Component
+}
+
+func splitTimezones(cal *ical.Calendar) (Locations, []*ical.Component) {
+	var timezones, children []*ical.Component
+	for _, child := range cal.Children {
+		if child.Name == ical.CompTimezone {
+			timezones = append(timezones, child)
+			continue
+		}
+		children = append(children, child)
+	}
+	return NewLocations(timezones), children
+}
+
+func groupRecurrences(children []*ical.Component) []*recurrenceGroup {
+	var groups []*recurrenceGroup
+	byUID := make(map[string]*recurrenceGroup)
+
+	for _, child := range children {
+		uid, _ := child.Props.Text(ical.PropUID)
+		group, ok := byUID[uid]
+		if !ok {
+			group = &recurrenceGroup{}
+			byUID[uid] = group
+			groups = append(groups, group)
+		}
+		if child.Props.Get(ical.PropRecurrenceID) != nil {
+			group.overrides = append(group.overrides, child)
+			continue
+		}
+		group.master = child
+	}
+	return groups
+}
+
+// recurrenceSet returns the instances of a component having RRULE or RDATE,
+// together with the durations of the instances given by a PERIOD, keyed by
+// their Unix time.
+func recurrenceSet(comp *ical.Component, locs Locations) (*rrule.Set, map[int64]time.Duration) {
+	roption, err := comp.Props.RecurrenceRule()
+	if err != nil {
+		roption = nil
+	}
+	dates := ScanRecurrenceDates(comp, locs)
+	if roption == nil && len(dates) == 0 {
+		return nil, nil
+	}
+	start, tzid := zonedTimeValue(comp, ical.PropDateTimeStart, locs)
+	if !start.Valid {
+		return nil, nil
+	}
+
+	set := &rrule.Set{}
+	if roption != nil {
+		roption.Dtstart = locs.In(start, tzid)
+		rule, err := rrule.NewRRule(*roption)
+		if err != nil {
+			return nil, nil
+		}
+		set.RRule(rule)
+	} else {
+		// DTSTART is the first instance of the set (RFC 5545 §3.8.5.2)
+		set.RDate(start.Time)
+	}
+
+	periods := make(map[int64]time.Duration)
+	for _, d := range dates {
+		set.RDate(d.Value.Time)
+		if d.End.Valid {
+			periods[d.Value.Time.Unix()] = d.End.Time.Sub(d.Value.Time)
+		}
+	}
+	for _, exDate := range zonedTimeValues(comp, ical.PropExceptionDates, locs) {
+		set.ExDate(exDate)
+	}
+	return set, periods
+}
+
+// openRangeInstances caps the instances of a time range without an end, which
+// never ends for a rule without COUNT or UNTIL.
+const openRangeInstances = 1000
+
+// instancesBetween returns the instances of the set in [start, end], or the
+// first openRangeInstances from start on if end is zero (RFC 4791 §9.9).
+func instancesBetween(set *rrule.Set, start, end time.Time) []time.Time {
+	if !end.IsZero() {
+		return set.Between(start, end, true)
+	}
+	var instances []time.Time
+	next := set.Iterator()
+	for len(instances) < openRangeInstances {
+		instance, ok := next()
+		if !ok {
+			break
+		}
+		if !instance.Before(start) {
+			instances = append(instances, instance)
+		}
+	}
+	return instances
+}
+
+func componentStart(comp *ical.Component, locs Locations) (time.Time, bool) {
+	start, _ := zonedTimeValue(comp, ical.PropDateTimeStart, locs)
+	return start.Time, start.Valid
+}
+
+func componentDuration(comp *ical.Component, locs Locations) time.Duration {
+	start, ok := componentStart(comp, locs)
+	if !ok {
+		return 0
+	}
+	if end, _ := zonedTimeValue(comp, ical.PropDateTimeEnd, locs); end.Valid {
+		return end.Time.Sub(start)
+	}
+	if end, _ := zonedTimeValue(comp, ical.PropDue, locs); end.Valid {
+		return end.Time.Sub(start)
+	}
+	if prop := comp.Props.Get(ical.PropDuration); prop != nil {
+		if duration, err := prop.Duration(); err == nil {
+			return duration
+		}
+	}
+	if prop := comp.Props.Get(ical.PropDateTimeStart); prop.ValueType() == ical.ValueDate {
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+func overlaps(comp *ical.Component, locs Locations, start, end time.Time) bool {
+	instance, ok := componentStart(comp, locs)
+	if !ok {
+		return true
+	}
+	return overlapsRange(instance, componentDuration(comp, locs), start, end)
+}
+
+// overlapsRange follows the VEVENT overlap rules of RFC 4791 §9.9.
+func overlapsRange(instance time.Time, duration time.Duration, start, end time.Time) bool {
+	if !end.IsZero() && !instance.Before(end) {
+		return false
+	}
+	if start.IsZero() {
+		return true
+	}
+	if duration == 0 {
+		return !instance.Before(start)
+	}
+	return instance.Add(duration).After(start)
+}
+
+func instanceComponent(master *ical.Component, locs Locations, instance time.Time, duration time.Duration) *ical.Component {
+	comp := utcComponent(master, locs)
+	comp.Props.Del(ical.PropRecurrenceRule)
+	comp.Props.Del(ical.PropRecurrenceDates)
+	comp.Props.Del(ical.PropExceptionDates)
+
+	isDate := master.Props.Get(ical.PropDateTimeStart).ValueType() == ical.ValueDate
+	setInstanceTime(comp, ical.PropRecurrenceID, instance, isDate)
+	setInstanceTime(comp, ical.PropDateTimeStart, instance, isDate)
+	if comp.Props.Get(ical.PropDateTimeEnd) != nil {
+		setInstanceTime(comp, ical.PropDateTimeEnd, instance.Add(duration), isDate)
+	}
+	if comp.Props.Get(ical.PropDue) != nil {
+		setInstanceTime(comp, ical.PropDue, instance.Add(duration), isDate)
+	}
+	return comp
+}
+
+func setInstanceTime(comp *ical.Component, propName string, value time.Time, isDate bool) {
+	prop := ical.NewProp(propName)
+	if isDate {
+		prop.SetDate(value)
+	} else {
+		prop.SetDateTime(value.UTC())
+	}
+	comp.Props.Set(prop)
+}
+
+// utcComponent returns a copy of the component with every TZID-qualified
+// DATE-TIME property converted to UTC.
+func utcComponent(comp *ical.Component, locs Locations) *ical.Component {
+	out := copyComponent(comp)
+	for _, propName := range []string{
+		ical.PropDateTimeStart,
+		ical.PropDateTimeEnd,
+		ical.PropDue,
+		ical.PropRecurrenceID,
+	} {
+		prop := out.Props.Get(propName)
+		if prop == nil || prop.Params.Get(ical.ParamTimezoneID) == "" {
+			continue
+		}
+		if value, _ := zonedTimeValue(comp, propName, locs); value.Valid {
+			setInstanceTime(out, propName, value.Time, false)
+		}
+	}
+	if exDates := out.Props.Get(ical.PropExceptionDates); exDates != nil && exDates.Params.Get(ical.ParamTimezoneID) != "" {
+		out.Props.Del(ical.PropExceptionDates)
+		for _, exDate := range zonedTimeValues(comp, ical.PropExceptionDates, locs) {
+			prop := ical.NewProp(ical.PropExceptionDates)
+			prop.SetDateTime(exDate)
+			out.Props.Add(prop)
+		}
+	}
+	return out
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"github.com/ceres919/go-webdav/caldav"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// CompFilter is the part of a calendar-query comp-filter that can be checked
+// against event_component columns. Matching objects still have to pass
+// caldav.Filter, so a CompFilter may only widen the result, never narrow it.
+type CompFilter struct {
+	CompType pgtype.Text      `json:"compType,omitempty"`
+	Start    pgtype.Timestamp `json:"start,omitempty"`
+	End      pgtype.Timestamp `json:"end,omitempty"`
+}
+
+func ScanCompFilters(filter *caldav.CompFilter) []CompFilter {
+	if filter == nil {
+		return nil
+	}
+
+	var filters []CompFilter
+	for _, comp := range filter.Comps {
+		if comp.IsNotDefined {
+			continue
+		}
+		cf := CompFilter{
+			CompType: ComponentType(comp.Name),
+			Start:    pgtype.Timestamp{Valid: false},
+			End:      pgtype.Timestamp{Valid: false},
+		}
+		if !comp.Start.IsZero() {
+			cf.Start = pgtype.Timestamp{Time: comp.Start.UTC(), Valid: true}
+		}
+		if !comp.End.IsZero() {
+			cf.End = pgtype.Timestamp{Time: comp.End.UTC(), Valid: true}
+		}
+		filters = append(filters, cf)
+	}
+	return filters
+}
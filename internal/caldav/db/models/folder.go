@@ -4,14 +4,19 @@ import (
 	"strconv"
 
 	"github.com/ceres919/go-webdav/caldav"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Folder struct {
-	ID          int      `json:"id"`
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	Types       []string `json:"types"`
-	Size        int64    `json:"size"`
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Types       []string    `json:"types"`
+	Size        int64       `json:"size"`
+	Revision    int64       `json:"revision"`
+	Color       pgtype.Text `json:"color,omitempty"`
+	Order       pgtype.Int4 `json:"order,omitempty"`
+	Timezone    pgtype.Text `json:"timezone,omitempty"`
 }
 
 func (f *Folder) ToDomain() caldav.Calendar {
@@ -23,3 +28,13 @@ func (f *Folder) ToDomain() caldav.Calendar {
 		MaxResourceSize:       f.Size,
 	}
 }
+
+// FolderPatch changes the properties of a folder. Nil fields are left as
+// they are, invalid ones are removed.
+type FolderPatch struct {
+	Name        *pgtype.Text
+	Description *pgtype.Text
+	Color       *pgtype.Text
+	Order       *pgtype.Int4
+	Timezone    *pgtype.Text
+}
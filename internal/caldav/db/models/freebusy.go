@@ -0,0 +1,125 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/google/uuid"
+)
+
+const productID = "-//xyz Protei-Lab//Raimguzhinov DAV-GO V1.0.0//EN"
+
+const (
+	fbTypeBusy          = "BUSY"
+	fbTypeBusyTentative = "BUSY-TENTATIVE"
+)
+
+type BusyPeriod struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Tentative bool      `json:"tentative"`
+}
+
+// NewBusyPeriod returns the busy time of an event instance, or false if the
+// instance does not block time.
+func NewBusyPeriod(start, end time.Time, status, transparency string) (BusyPeriod, bool) {
+	if strings.EqualFold(status, "CANCELLED") || strings.EqualFold(transparency, "TRANSPARENT") {
+		return BusyPeriod{}, false
+	}
+	if !end.After(start) {
+		return BusyPeriod{}, false
+	}
+	return BusyPeriod{
+		Start:     start.UTC(),
+		End:       end.UTC(),
+		Tentative: strings.EqualFold(status, "TENTATIVE"),
+	}, true
+}
+
+// ScanBusyPeriods returns the busy time of the events of an expanded calendar.
+func ScanBusyPeriods(cal *ical.Calendar) []BusyPeriod {
+	locs, children := splitTimezones(cal)
+
+	var periods []BusyPeriod
+	for _, child := range children {
+		if child.Name != ical.CompEvent {
+			continue
+		}
+		start, ok := componentStart(child, locs)
+		if !ok {
+			continue
+		}
+		status, _ := child.Props.Text(ical.PropStatus)
+		transparency, _ := child.Props.Text(ical.PropTransparency)
+
+		period, ok := NewBusyPeriod(start, start.Add(componentDuration(child, locs)), status, transparency)
+		if ok {
+			periods = append(periods, period)
+		}
+	}
+	return periods
+}
+
+// NewFreeBusy builds the VFREEBUSY answer of a free-busy-query REPORT. Busy
+// periods are clipped to [start, end) and merged per FBTYPE (RFC 4791 §7.10).
+func NewFreeBusy(start, end time.Time, periods []BusyPeriod) *ical.Calendar {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, productID)
+
+	fb := ical.NewComponent(ical.CompFreeBusy)
+	fb.Props.SetText(ical.PropUID, uuid.NewString())
+	fb.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+	fb.Props.SetDateTime(ical.PropDateTimeStart, start.UTC())
+	fb.Props.SetDateTime(ical.PropDateTimeEnd, end.UTC())
+
+	for _, fbType := range []string{fbTypeBusy, fbTypeBusyTentative} {
+		var typed []BusyPeriod
+		for _, p := range periods {
+			if p.Tentative != (fbType == fbTypeBusyTentative) {
+				continue
+			}
+			if p.Start.Before(start) {
+				p.Start = start
+			}
+			if p.End.After(end) {
+				p.End = end
+			}
+			if p.End.After(p.Start) {
+				typed = append(typed, p)
+			}
+		}
+
+		for _, p := range mergeBusyPeriods(typed) {
+			prop := ical.NewProp(ical.PropFreeBusy)
+			if fbType != fbTypeBusy {
+				prop.Params.Set(ical.ParamFreeBusyType, fbType)
+			}
+			prop.Value = p.Start.UTC().Format(datetimeUTCFormat) + "/" + p.End.UTC().Format(datetimeUTCFormat)
+			fb.Props.Add(prop)
+		}
+	}
+
+	cal.Children = append(cal.Children, fb)
+	return cal
+}
+
+func mergeBusyPeriods(periods []BusyPeriod) []BusyPeriod {
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].Start.Before(periods[j].Start)
+	})
+
+	var merged []BusyPeriod
+	for _, p := range periods {
+		if n := len(merged); n > 0 && !p.Start.After(merged[n-1].End) {
+			if p.End.After(merged[n-1].End) {
+				merged[n-1].End = p.End
+			}
+			continue
+		}
+		merged = append(merged, p)
+	}
+	return merged
+}
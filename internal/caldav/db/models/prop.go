@@ -4,6 +4,7 @@ import (
 	"math"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/emersion/go-ical"
@@ -11,6 +12,8 @@ import (
 )
 
 const (
+	dateFormat        = "20060102"
+	datetimeFormat    = "20060102T150405"
 	datetimeUTCFormat = "20060102T150405Z"
 )
 
@@ -57,6 +60,62 @@ func timeValue(event *ical.Component, propName string) pgtype.Timestamp {
 	return pgtype.Timestamp{Time: val.UTC(), Valid: true}
 }
 
+// zonedTimeValue returns the UTC time of the property together with the TZID
+// its local time was given in, if any.
+func zonedTimeValue(event *ical.Component, propName string, locs Locations) (pgtype.Timestamp, pgtype.Text) {
+	prop := event.Props.Get(propName)
+	if prop == nil {
+		return pgtype.Timestamp{Valid: false}, pgtype.Text{Valid: false}
+	}
+	tzid := prop.Params.Get(ical.ParamTimezoneID)
+	if tzid == "" || len(prop.Value) != len(datetimeFormat) {
+		return timeValue(event, propName), pgtype.Text{Valid: false}
+	}
+	val, err := time.ParseInLocation(datetimeFormat, prop.Value, locs.Get(tzid))
+	if err != nil {
+		return pgtype.Timestamp{Valid: false}, pgtype.Text{Valid: false}
+	}
+	return pgtype.Timestamp{Time: val.UTC(), Valid: true}, pgtype.Text{String: tzid, Valid: true}
+}
+
+// zonedTimeValues returns UTC times of every value of a multi-valued property.
+func zonedTimeValues(event *ical.Component, propName string, locs Locations) []time.Time {
+	var values []time.Time
+	for _, prop := range event.Props.Values(propName) {
+		loc := time.UTC
+		if tzid := prop.Params.Get(ical.ParamTimezoneID); tzid != "" {
+			loc = locs.Get(tzid)
+		}
+		for _, value := range strings.Split(prop.Value, ",") {
+			if val, ok := parseTimeValue(value, loc); ok {
+				values = append(values, val)
+			}
+		}
+	}
+	return values
+}
+
+// parseTimeValue returns the UTC time of a DATE or DATE-TIME value, local
+// times being given in loc.
+func parseTimeValue(value string, loc *time.Location) (time.Time, bool) {
+	var val time.Time
+	var err error
+	switch len(value) {
+	case len(datetimeUTCFormat):
+		val, err = time.Parse(datetimeUTCFormat, value)
+	case len(datetimeFormat):
+		val, err = time.ParseInLocation(datetimeFormat, value, loc)
+	case len(dateFormat):
+		val, err = time.ParseInLocation(dateFormat, value, loc)
+	default:
+		return time.Time{}, false
+	}
+	if err != nil {
+		return time.Time{}, false
+	}
+	return val.UTC(), true
+}
+
 func setTextValue(event *ical.Event, propName string, text pgtype.Text) {
 	if text.Valid {
 		event.Props.SetText(propName, text.String)
@@ -78,6 +137,27 @@ func setTimestampValue(event *ical.Event, propName string, value pgtype.Timestam
 	}
 }
 
+func setZonedTimestampValue(
+	event *ical.Event,
+	propName string,
+	value pgtype.Timestamp,
+	tzid pgtype.Text,
+	locs Locations,
+) {
+	if !value.Valid {
+		return
+	}
+	if !tzid.Valid {
+		setTimestampValue(event, propName, value)
+		return
+	}
+	prop := ical.NewProp(propName)
+	prop.SetValueType(ical.ValueDateTime)
+	prop.Params.Set(ical.ParamTimezoneID, tzid.String)
+	prop.Value = locs.In(value, tzid).Format(datetimeFormat)
+	event.Props.Set(prop)
+}
+
 func toJSONFormat(icalValue string, icalType ical.ValueType) map[ical.ValueType]any {
 	valueType := make(map[ical.ValueType]any)
 
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"sort"
+
+	"github.com/emersion/go-ical"
+)
+
+// modelledProps are the properties of a component kept in dedicated columns
+// and tables, any other one is kept as a Property.
+var modelledProps = map[string]bool{
+	ical.PropUID:             true,
+	ical.PropDateTimeStamp:   true,
+	ical.PropCreated:         true,
+	ical.PropLastModified:    true,
+	ical.PropSummary:         true,
+	ical.PropDescription:     true,
+	ical.PropURL:             true,
+	ical.PropOrganizer:       true,
+	ical.PropClass:           true,
+	ical.PropLocation:        true,
+	ical.PropStatus:          true,
+	ical.PropCategories:      true,
+	ical.PropDateTimeStart:   true,
+	ical.PropDateTimeEnd:     true,
+	ical.PropDue:             true,
+	ical.PropDuration:        true,
+	ical.PropPriority:        true,
+	ical.PropSequence:        true,
+	ical.PropCompleted:       true,
+	ical.PropPercentComplete: true,
+	ical.PropTransparency:    true,
+	ical.PropAttendee:        true,
+	ical.PropAttach:          true,
+	ical.PropRelatedTo:       true,
+	ical.PropRecurrenceRule:  true,
+	ical.PropRecurrenceDates: true,
+	ical.PropExceptionDates:  true,
+	ical.PropRecurrenceID:    true,
+}
+
+// Property is a property of a component the server does not model, such as
+// GEO, COMMENT or an X- property. Its value and parameters are kept as given.
+type Property struct {
+	Name   string      `json:"name"`
+	Params ical.Params `json:"params,omitempty"`
+	Value  string      `json:"value"`
+}
+
+// ScanProperties returns every value of the properties of the component which
+// are not modelled, ordered by name and then as given.
+func ScanProperties(event *ical.Component) []Property {
+	names := make([]string, 0, len(event.Props))
+	for name := range event.Props {
+		if !modelledProps[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var props []Property
+	for _, name := range names {
+		for _, prop := range event.Props[name] {
+			props = append(props, Property{
+				Name:   prop.Name,
+				Params: prop.Params,
+				Value:  prop.Value,
+			})
+		}
+	}
+	return props
+}
+
+func (p *Property) ToDomain() *ical.Prop {
+	prop := ical.NewProp(p.Name)
+	for name, values := range p.Params {
+		prop.Params[name] = append([]string(nil), values...)
+	}
+	prop.Value = p.Value
+	return prop
+}
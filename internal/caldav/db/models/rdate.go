@@ -0,0 +1,131 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RecurrenceDate is a value of RDATE. The end of a PERIOD is kept as given in
+// Period, either a date-time or a duration, and resolved in End for search.
+type RecurrenceDate struct {
+	Value     pgtype.Timestamp `json:"value"`
+	End       pgtype.Timestamp `json:"end,omitempty"`
+	Period    pgtype.Text      `json:"period,omitempty"`
+	TZID      pgtype.Text      `json:"tzid,omitempty"`
+	ValueType pgtype.Text      `json:"valueType"`
+}
+
+// ScanRecurrenceDates returns every value of the RDATE properties of the
+// component. It does not rely on ical.Component.RecurrenceSet, which reads
+// EXDATE in place of RDATE.
+func ScanRecurrenceDates(event *ical.Component, locs Locations) []RecurrenceDate {
+	var dates []RecurrenceDate
+	for _, prop := range event.Props.Values(ical.PropRecurrenceDates) {
+		loc := time.UTC
+		tzid := paramValue(prop, ical.ParamTimezoneID)
+		if tzid.Valid {
+			loc = locs.Get(tzid.String)
+		}
+		valueType := prop.ValueType()
+		if valueType == ical.ValueDefault {
+			valueType = ical.ValueDateTime
+		}
+
+		for _, value := range strings.Split(prop.Value, ",") {
+			d := RecurrenceDate{
+				TZID:      tzid,
+				ValueType: pgtype.Text{String: string(valueType), Valid: true},
+			}
+			start, period, isPeriod := strings.Cut(value, "/")
+			if isPeriod != (valueType == ical.ValuePeriod) {
+				continue
+			}
+			// Only local date-times depend on TZID, dates are kept at UTC
+			// midnight like DTSTART
+			startLoc := loc
+			if len(start) != len(datetimeFormat) {
+				startLoc = time.UTC
+				d.TZID = pgtype.Text{Valid: false}
+			}
+			val, ok := parseTimeValue(start, startLoc)
+			if !ok {
+				continue
+			}
+			d.Value = pgtype.Timestamp{Time: val, Valid: true}
+			if isPeriod {
+				end, ok := periodEnd(val, period, loc)
+				if !ok {
+					continue
+				}
+				d.End = pgtype.Timestamp{Time: end, Valid: true}
+				d.Period = pgtype.Text{String: period, Valid: true}
+			}
+			dates = append(dates, d)
+		}
+	}
+	return dates
+}
+
+// periodEnd resolves the end of a PERIOD starting at start, given either as
+// a date-time or as a duration.
+func periodEnd(start time.Time, period string, loc *time.Location) (time.Time, bool) {
+	if period == "" {
+		return time.Time{}, false
+	}
+	if c := period[0]; c == 'P' || c == '+' || c == '-' {
+		prop := ical.NewProp(ical.PropDuration)
+		prop.Value = period
+		duration, err := prop.Duration()
+		if err != nil {
+			return time.Time{}, false
+		}
+		return start.Add(duration), true
+	}
+	end, ok := parseTimeValue(period, loc)
+	if !ok || len(period) == len(dateFormat) {
+		return time.Time{}, false
+	}
+	return end, true
+}
+
+// RecurrenceDatesToDomain returns the RDATE properties of the dates, one per
+// run of values sharing the value type and the time zone.
+func RecurrenceDatesToDomain(dates []RecurrenceDate, locs Locations) []*ical.Prop {
+	var props []*ical.Prop
+	var prop *ical.Prop
+	for i := range dates {
+		d := &dates[i]
+		if prop == nil || prop.ValueType() != ical.ValueType(d.ValueType.String) ||
+			prop.Params.Get(ical.ParamTimezoneID) != d.TZID.String {
+			prop = ical.NewProp(ical.PropRecurrenceDates)
+			prop.SetValueType(ical.ValueType(d.ValueType.String))
+			setParamValue(prop, ical.ParamTimezoneID, d.TZID)
+			props = append(props, prop)
+		} else {
+			prop.Value += ","
+		}
+		prop.Value += d.format(locs)
+	}
+	return props
+}
+
+func (d *RecurrenceDate) format(locs Locations) string {
+	value := locs.In(d.Value, d.TZID)
+	if d.ValueType.String == string(ical.ValueDate) {
+		return value.Format(dateFormat)
+	}
+
+	var s string
+	if d.TZID.Valid {
+		s = value.Format(datetimeFormat)
+	} else {
+		s = value.Format(datetimeUTCFormat)
+	}
+	if d.Period.Valid {
+		s += "/" + d.Period.String
+	}
+	return s
+}
@@ -14,8 +14,8 @@ type RecurrenceException struct {
 	IsDeleted pgtype.Text      `json:"isDeleted"`
 }
 
-func ScanRecurrenceException(event *ical.Component) *RecurrenceException {
-	exRecurrenceID := timeValue(event, ical.PropRecurrenceID)
+func ScanRecurrenceException(event *ical.Component, locs Locations) *RecurrenceException {
+	exRecurrenceID, _ := zonedTimeValue(event, ical.PropRecurrenceID, locs)
 	if exRecurrenceID.Valid {
 		return &RecurrenceException{
 			Value:     exRecurrenceID,
@@ -25,11 +25,20 @@ func ScanRecurrenceException(event *ical.Component) *RecurrenceException {
 	return nil
 }
 
-func (r *RecurrenceException) ToDomain() string {
-	return r.Value.Time.UTC().Format(datetimeUTCFormat)
+func (r *RecurrenceException) ToDomain(loc *time.Location) string {
+	if loc == nil || loc == time.UTC {
+		return r.Value.Time.UTC().Format(datetimeUTCFormat)
+	}
+	return r.Value.Time.In(loc).Format(datetimeFormat)
 }
 
+// RecurrenceSet keeps the RRULE as given in Rule, the other fields break it
+// down for search. Rows stored before Rule was introduced are rebuilt from
+// them. A set may have RDATEs only, then it has no rule. ThisAndFuture is set
+// for a series split off another one at a RANGE=THISANDFUTURE override.
 type RecurrenceSet struct {
+	Frequency     pgtype.Text            `json:"frequency,omitempty"`
+	Rule          pgtype.Text            `json:"rule,omitempty"`
 	Interval      pgtype.Uint32          `json:"interval,omitempty"`
 	Cnt           pgtype.Uint32          `json:"cnt,omitempty"`
 	Until         pgtype.Date            `json:"until,omitempty"`
@@ -40,18 +49,50 @@ type RecurrenceSet struct {
 	Months        pgtype.Uint32          `json:"months,omitempty"`
 	PeriodDay     *int                   `json:"periodDay,omitempty"`
 	ThisAndFuture pgtype.Text            `json:"thisAndFuture,omitempty"`
+	Dates         []RecurrenceDate       `json:"dates,omitempty"`
 	Exceptions    []*RecurrenceException `json:"exceptions,omitempty"`
 }
 
-func ScanRecurrence(event *ical.Component) *RecurrenceSet {
-	recurrenceSet, err := event.RecurrenceSet(time.UTC)
+// ValidRecurrences reports whether the RRULE of every component of the
+// calendar can be expanded. ScanRecurrence leaves out the rules which can't.
+func ValidRecurrences(cal *ical.Calendar) bool {
+	for _, child := range cal.Children {
+		roption, err := child.Props.RecurrenceRule()
+		if err != nil {
+			return false
+		}
+		if roption == nil {
+			continue
+		}
+		if _, err := rrule.NewRRule(*roption); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func ScanRecurrence(event *ical.Component, locs Locations) *RecurrenceSet {
+	roption, err := event.Props.RecurrenceRule()
 	if err != nil {
+		roption = nil
+	}
+	dates := ScanRecurrenceDates(event, locs)
+	if roption == nil && len(dates) == 0 {
 		return nil
 	}
-	if recurrenceSet == nil {
+	start, tzid := zonedTimeValue(event, ical.PropDateTimeStart, locs)
+	if !start.Valid {
 		return nil
 	}
 
+	var rule *rrule.RRule
+	if roption != nil {
+		roption.Dtstart = locs.In(start, tzid)
+		if rule, err = rrule.NewRRule(*roption); err != nil {
+			return nil
+		}
+	}
+
 	standardDay := map[rrule.Weekday]time.Weekday{
 		rrule.SU: time.Sunday,
 		rrule.MO: time.Monday,
@@ -71,19 +112,27 @@ func ScanRecurrence(event *ical.Component) *RecurrenceSet {
 		Weekdays:      pgtype.Uint32{Valid: false},
 		Monthdays:     pgtype.Uint32{Valid: false},
 		Months:        pgtype.Uint32{Valid: false},
-		ThisAndFuture: pgtype.Text{String: "1", Valid: true},
+		ThisAndFuture: BitNone,
+		Dates:         dates,
 	}
 
-	if recurrenceSet.GetExDate() != nil {
-		rs.Exceptions = make([]*RecurrenceException, len(recurrenceSet.GetExDate()))
-		for i, exDate := range recurrenceSet.GetExDate() {
+	if exDates := zonedTimeValues(event, ical.PropExceptionDates, locs); exDates != nil {
+		rs.Exceptions = make([]*RecurrenceException, len(exDates))
+		for i, exDate := range exDates {
 			rs.Exceptions[i] = &RecurrenceException{
 				Value: pgtype.Timestamp{Time: exDate, Valid: true},
 			}
 		}
 	}
 
-	options := recurrenceSet.GetRRule().Options
+	if rule == nil {
+		return rs
+	}
+
+	options := rule.Options
+
+	rs.Frequency = pgtype.Text{String: options.Freq.String(), Valid: true}
+	rs.Rule = pgtype.Text{String: event.Props.Get(ical.PropRecurrenceRule).Value, Valid: true}
 
 	if options.Interval != 0 {
 		rs.Interval = pgtype.Uint32{Uint32: uint32(options.Interval), Valid: true}
@@ -93,7 +142,6 @@ func ScanRecurrence(event *ical.Component) *RecurrenceSet {
 	}
 	if !options.Until.IsZero() {
 		rs.Until = pgtype.Date{Time: options.Until, Valid: true}
-		rs.ThisAndFuture = pgtype.Text{String: "0", Valid: true}
 	}
 	if options.Wkst.String() != "" {
 		rs.Wkst = pgtype.Uint32{Uint32: uint32(standardDay[options.Wkst]), Valid: true}
@@ -120,7 +168,10 @@ func ScanRecurrence(event *ical.Component) *RecurrenceSet {
 	return rs
 }
 
-func (rs *RecurrenceSet) ToDomain() (*rrule.ROption, string) {
+// ToDomain returns the RRULE and the EXDATE value of the set, dtstart gives
+// the time zone of the exceptions.
+func (rs *RecurrenceSet) ToDomain(dtstart time.Time) (*ical.Prop, string) {
+	loc := dtstart.Location()
 	ro := rrule.ROption{Freq: rrule.SECONDLY}
 
 	rruleDay := map[time.Weekday]rrule.Weekday{
@@ -190,17 +241,25 @@ func (rs *RecurrenceSet) ToDomain() (*rrule.ROption, string) {
 	for i, exception := range rs.Exceptions {
 		if exception.Value.Valid {
 			if i == 0 {
-				exString = exception.ToDomain()
+				exString = exception.ToDomain(loc)
 				continue
 			}
-			exString = fmt.Sprintf("%s,%s", exString, exception.ToDomain())
+			exString = fmt.Sprintf("%s,%s", exString, exception.ToDomain(loc))
 		}
 	}
 
-	if ro.Freq == rrule.SECONDLY {
+	prop := ical.NewProp(ical.PropRecurrenceRule)
+	prop.SetValueType(ical.ValueRecurrence)
+	switch {
+	case rs.Rule.Valid:
+		prop.Value = rs.Rule.String
+	case ro.Freq != rrule.SECONDLY:
+		ro.Dtstart = dtstart.UTC()
+		prop.Value = ro.RRuleString()
+	default:
 		return nil, exString
 	}
-	return &ro, exString
+	return prop, exString
 }
 
 func getMasks(options *rrule.ROption, standardDay map[rrule.Weekday]time.Weekday) (*time.Weekday, *int, *time.Month, *int) {
@@ -219,7 +278,7 @@ func getMasks(options *rrule.ROption, standardDay map[rrule.Weekday]time.Weekday
 		case rrule.DAILY:
 			weekdays = 127
 		case rrule.WEEKLY:
-			weekdays |= 1 << options.Dtstart.UTC().Weekday()
+			weekdays |= 1 << options.Dtstart.Weekday()
 		default:
 		}
 	}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/emersion/go-ical"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const relTypeParent = "PARENT"
+
+// Relation is a RELATED-TO of the component. Without RELTYPE the related
+// component is the parent (RFC 5545 §3.2.15).
+type Relation struct {
+	RelatedUID       pgtype.Text `json:"relatedUid"`
+	RelationshipType pgtype.Text `json:"relationshipType,omitempty"`
+}
+
+// ScanRelations returns every RELATED-TO of the component.
+func ScanRelations(event *ical.Component) []Relation {
+	var relations []Relation
+	for _, prop := range event.Props.Values(ical.PropRelatedTo) {
+		if prop.Value == "" {
+			continue
+		}
+		relations = append(relations, Relation{
+			RelatedUID:       pgtype.Text{String: prop.Value, Valid: true},
+			RelationshipType: paramValue(prop, ical.ParamRelationshipType),
+		})
+	}
+	return relations
+}
+
+// IsParent reports whether the related component is the parent.
+func (r *Relation) IsParent() bool {
+	return !r.RelationshipType.Valid || strings.EqualFold(r.RelationshipType.String, relTypeParent)
+}
+
+func (r *Relation) ToDomain() *ical.Prop {
+	prop := ical.NewProp(ical.PropRelatedTo)
+	prop.Value = r.RelatedUID.String
+	setParamValue(prop, ical.ParamRelationshipType, r.RelationshipType)
+	return prop
+}
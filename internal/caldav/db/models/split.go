@@ -0,0 +1,345 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/teambition/rrule-go"
+)
+
+// rangeThisAndFuture is the RANGE of a RECURRENCE-ID overriding the instance
+// and every later one (RFC 5545 §3.2.13).
+const rangeThisAndFuture = "THISANDFUTURE"
+
+// SplitThisAndFuture splits every recurring component of the calendar at its
+// RANGE=THISANDFUTURE overrides: the master ends before the overridden
+// instance and the override starts a new series. A calendar object resource
+// holds a single UID (RFC 4791 §4.1), so new series are returned as calendars
+// of their own, with a UID derived from the one of the master. The calendar is
+// returned as is if it has no such override.
+func SplitThisAndFuture(cal *ical.Calendar) (*ical.Calendar, []*ical.Calendar) {
+	if !slices.ContainsFunc(cal.Children, isThisAndFuture) {
+		return cal, nil
+	}
+
+	locs, children := splitTimezones(cal)
+	var timezones []*ical.Component
+	for _, child := range cal.Children {
+		if child.Name == ical.CompTimezone {
+			timezones = append(timezones, child)
+		}
+	}
+
+	out := &ical.Calendar{Component: &ical.Component{Name: cal.Name, Props: cal.Props}}
+	out.Children = append(out.Children, timezones...)
+
+	var series []*ical.Calendar
+	for _, group := range groupRecurrences(children) {
+		for {
+			head, tail := splitGroup(group, locs)
+			if tail == nil {
+				out.Children = append(out.Children, head...)
+				break
+			}
+			if head == nil {
+				// The series is overridden from its first instance on, the
+				// override takes over under the same UID
+				group = tail
+				continue
+			}
+			out.Children = append(out.Children, head...)
+
+			next := &ical.Calendar{Component: &ical.Component{Name: cal.Name, Props: cal.Props}}
+			next.Children = append(next.Children, timezones...)
+			next.Children = append(next.Children, tail.components()...)
+			next, more := SplitThisAndFuture(next)
+			series = append(series, next)
+			series = append(series, more...)
+			break
+		}
+	}
+	return out, series
+}
+
+func isThisAndFuture(comp *ical.Component) bool {
+	rid := comp.Props.Get(ical.PropRecurrenceID)
+	return rid != nil && strings.EqualFold(rid.Params.Get(ical.ParamRange), rangeThisAndFuture)
+}
+
+func (g *recurrenceGroup) components() []*ical.Component {
+	var comps []*ical.Component
+	if g.master != nil {
+		comps = append(comps, g.master)
+	}
+	return append(comps, g.overrides...)
+}
+
+// splitGroup splits the group at its earliest THISANDFUTURE override. The
+// components of the group are returned as head if there is nothing to split,
+// head is nil if the override starts with the first instance.
+func splitGroup(group *recurrenceGroup, locs Locations) ([]*ical.Component, *recurrenceGroup) {
+	var split *ical.Component
+	var at time.Time
+	for _, override := range group.overrides {
+		if !isThisAndFuture(override) {
+			continue
+		}
+		rid, _ := zonedTimeValue(override, ical.PropRecurrenceID, locs)
+		if rid.Valid && (split == nil || rid.Time.Before(at)) {
+			split, at = override, rid.Time
+		}
+	}
+	if split == nil || group.master == nil {
+		return group.components(), nil
+	}
+	start, ok := componentStart(group.master, locs)
+	if !ok {
+		return group.components(), nil
+	}
+	delta := time.Duration(0)
+	if overrideStart, ok := componentStart(split, locs); ok {
+		delta = overrideStart.Sub(at)
+	}
+
+	uid, _ := group.master.Props.Text(ical.PropUID)
+	tailUID := uid
+	if at.After(start) {
+		tailUID = fmt.Sprintf("%s-%s", uid, at.UTC().Format(datetimeUTCFormat))
+	}
+
+	master := copyComponent(split)
+	master.Props.Del(ical.PropRecurrenceID)
+	master.Props.SetText(ical.PropUID, tailUID)
+	tail := &recurrenceGroup{master: master}
+
+	// The override may give a recurrence of its own for the new series,
+	// otherwise it goes on with the one of the master
+	ownRecurrence := master.Props.Get(ical.PropRecurrenceRule) != nil ||
+		master.Props.Get(ical.PropRecurrenceDates) != nil
+
+	headMaster := copyComponent(group.master)
+	headRule, tailRule := splitRule(group.master, locs, at)
+	if headRule != nil {
+		headMaster.Props.Set(headRule)
+	}
+	if tailRule != nil && !ownRecurrence {
+		master.Props.Set(tailRule)
+	}
+	for _, propName := range []string{ical.PropRecurrenceDates, ical.PropExceptionDates} {
+		before, after := splitDates(group.master, propName, locs, at, delta)
+		headMaster.Props.Del(propName)
+		if len(before) > 0 {
+			headMaster.Props[propName] = before
+		}
+		if ownRecurrence {
+			continue
+		}
+		master.Props.Del(propName)
+		if len(after) > 0 {
+			master.Props[propName] = after
+		}
+	}
+
+	head := []*ical.Component{headMaster}
+	for _, override := range group.overrides {
+		if override == split {
+			continue
+		}
+		rid, _ := zonedTimeValue(override, ical.PropRecurrenceID, locs)
+		if !rid.Valid || rid.Time.Before(at) {
+			head = append(head, override)
+			continue
+		}
+		// Later instances of the new series are shifted along with it
+		comp := copyComponent(override)
+		comp.Props.SetText(ical.PropUID, tailUID)
+		if prop := shiftedProp(override.Props.Get(ical.PropRecurrenceID), locs, delta); prop != nil {
+			comp.Props.Set(prop)
+		}
+		tail.overrides = append(tail.overrides, comp)
+	}
+
+	if tailUID == uid {
+		return nil, tail
+	}
+	return head, tail
+}
+
+// splitRule returns the RRULE of the master ending before at and the one of
+// the series starting at at, nil if the master has no instance left from at
+// on. A COUNT is shared between both, otherwise the master is given an UNTIL
+// unless it ends earlier, and the series keeps the rule as is.
+func splitRule(master *ical.Component, locs Locations, at time.Time) (*ical.Prop, *ical.Prop) {
+	prop := master.Props.Get(ical.PropRecurrenceRule)
+	if prop == nil {
+		return nil, nil
+	}
+	roption, err := master.Props.RecurrenceRule()
+	if err != nil || roption == nil {
+		return nil, nil
+	}
+
+	head := ical.NewProp(ical.PropRecurrenceRule)
+	tail := ical.NewProp(ical.PropRecurrenceRule)
+	tail.Value = prop.Value
+
+	if roption.Count == 0 {
+		// A series already ending before the split keeps its end, the
+		// override is then a single instance
+		if !roption.Until.IsZero() && roption.Until.Before(at) {
+			head.Value = prop.Value
+			return head, nil
+		}
+		head.Value = withRuleLimit(prop.Value, "UNTIL", untilValue(master, at))
+		return head, tail
+	}
+
+	start, tzid := zonedTimeValue(master, ical.PropDateTimeStart, locs)
+	roption.Dtstart = locs.In(start, tzid)
+	rule, err := rrule.NewRRule(*roption)
+	if err != nil {
+		return nil, nil
+	}
+	count := len(rule.Between(start.Time, at, false))
+	if !at.Equal(start.Time) {
+		count++
+	}
+	head.Value = withRuleLimit(prop.Value, "COUNT", strconv.Itoa(count))
+	if count >= roption.Count {
+		return head, nil
+	}
+	tail.Value = withRuleLimit(prop.Value, "COUNT", strconv.Itoa(roption.Count-count))
+	return head, tail
+}
+
+// withRuleLimit replaces the COUNT or UNTIL of the RRULE value.
+func withRuleLimit(rule, name, value string) string {
+	var parts []string
+	for _, part := range strings.Split(rule, ";") {
+		key, _, _ := strings.Cut(part, "=")
+		if strings.EqualFold(key, "COUNT") || strings.EqualFold(key, "UNTIL") {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(append(parts, name+"="+value), ";")
+}
+
+// untilValue returns the last UNTIL before at, of the value type of DTSTART
+// (RFC 5545 §3.3.10).
+func untilValue(master *ical.Component, at time.Time) string {
+	prop := master.Props.Get(ical.PropDateTimeStart)
+	switch {
+	case prop.ValueType() == ical.ValueDate:
+		return at.AddDate(0, 0, -1).Format(dateFormat)
+	case prop.Params.Get(ical.ParamTimezoneID) == "" && len(prop.Value) == len(datetimeFormat):
+		return at.Add(-time.Second).Format(datetimeFormat)
+	}
+	return at.Add(-time.Second).UTC().Format(datetimeUTCFormat)
+}
+
+// splitDates splits the values of a multi-valued date property at at, the
+// values from at on are shifted by delta.
+func splitDates(comp *ical.Component, propName string, locs Locations, at time.Time, delta time.Duration) (
+	[]ical.Prop,
+	[]ical.Prop,
+) {
+	var before, after []ical.Prop
+	for _, prop := range comp.Props.Values(propName) {
+		loc := time.UTC
+		if tzid := prop.Params.Get(ical.ParamTimezoneID); tzid != "" {
+			loc = locs.Get(tzid)
+		}
+		var beforeValues, afterValues []string
+		for _, value := range strings.Split(prop.Value, ",") {
+			start, _, _ := strings.Cut(value, "/")
+			val, ok := parseTimeValue(start, loc)
+			if !ok {
+				continue
+			}
+			if val.Before(at) {
+				beforeValues = append(beforeValues, value)
+			} else if shifted, ok := shiftValue(value, loc, delta); ok {
+				afterValues = append(afterValues, shifted)
+			}
+		}
+		if len(beforeValues) > 0 {
+			before = append(before, ical.Prop{Name: prop.Name, Params: prop.Params, Value: strings.Join(beforeValues, ",")})
+		}
+		if len(afterValues) > 0 {
+			after = append(after, ical.Prop{Name: prop.Name, Params: prop.Params, Value: strings.Join(afterValues, ",")})
+		}
+	}
+	return before, after
+}
+
+// shiftedProp returns a copy of the date property shifted by delta.
+func shiftedProp(prop *ical.Prop, locs Locations, delta time.Duration) *ical.Prop {
+	if prop == nil {
+		return nil
+	}
+	loc := time.UTC
+	if tzid := prop.Params.Get(ical.ParamTimezoneID); tzid != "" {
+		loc = locs.Get(tzid)
+	}
+	value, ok := shiftValue(prop.Value, loc, delta)
+	if !ok {
+		return nil
+	}
+	return &ical.Prop{Name: prop.Name, Params: prop.Params, Value: value}
+}
+
+// shiftValue shifts a DATE, DATE-TIME or PERIOD value by delta, keeping its
+// format. Durations of periods are left as they are.
+func shiftValue(value string, loc *time.Location, delta time.Duration) (string, bool) {
+	start, end, isPeriod := strings.Cut(value, "/")
+	shifted, ok := shiftTimeValue(start, loc, delta)
+	if !ok {
+		return "", false
+	}
+	if !isPeriod {
+		return shifted, true
+	}
+	if end == "" {
+		return "", false
+	}
+	if c := end[0]; c != 'P' && c != '+' && c != '-' {
+		if end, ok = shiftTimeValue(end, loc, delta); !ok {
+			return "", false
+		}
+	}
+	return shifted + "/" + end, true
+}
+
+func shiftTimeValue(value string, loc *time.Location, delta time.Duration) (string, bool) {
+	val, ok := parseTimeValue(value, loc)
+	if !ok {
+		return "", false
+	}
+	val = val.Add(delta)
+	switch len(value) {
+	case len(datetimeUTCFormat):
+		return val.Format(datetimeUTCFormat), true
+	case len(datetimeFormat):
+		return val.In(loc).Format(datetimeFormat), true
+	}
+	return val.In(loc).Format(dateFormat), true
+}
+
+// copyComponent returns a copy of the component whose properties can be
+// changed, sub-components are shared.
+func copyComponent(comp *ical.Component) *ical.Component {
+	out := &ical.Component{
+		Name:     comp.Name,
+		Props:    make(ical.Props, len(comp.Props)),
+		Children: comp.Children,
+	}
+	for name, props := range comp.Props {
+		out.Props[name] = append([]ical.Prop(nil), props...)
+	}
+	return out
+}
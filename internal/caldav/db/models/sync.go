@@ -0,0 +1,17 @@
+package models
+
+import (
+	"github.com/ceres919/go-webdav/caldav"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// SyncChanges lists the calendar objects of a folder changed after a revision.
+type SyncChanges struct {
+	// Revision is the folder revision the changes are complete up to.
+	Revision int64 `json:"revision"`
+	// MinRevision is the oldest revision still kept in the change log.
+	MinRevision pgtype.Int8             `json:"minRevision,omitempty"`
+	Changed     []caldav.CalendarObject `json:"changed,omitempty"`
+	Removed     []string                `json:"removed,omitempty"`
+	Truncated   bool                    `json:"truncated"`
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const relTypeChild = "CHILD"
+
+// Task is a VTODO of a folder with its subtasks.
+type Task struct {
+	UID             string           `json:"uid"`
+	Summary         pgtype.Text      `json:"summary,omitempty"`
+	Status          pgtype.Text      `json:"status,omitempty"`
+	Due             pgtype.Timestamp `json:"due,omitempty"`
+	Completed       pgtype.Timestamp `json:"completed,omitempty"`
+	PercentComplete pgtype.Uint32    `json:"percentComplete,omitempty"`
+	Relations       []Relation       `json:"relations,omitempty"`
+	Subtasks        []*Task          `json:"subtasks,omitempty"`
+}
+
+// BuildTaskTree links the tasks to their parents, given either by a PARENT
+// relation of the subtask or a CHILD relation of the parent, and returns the
+// top-level tasks. Tasks whose parent is not among tasks are top-level as
+// well, and so are those of a cycle, so that every task is part of the tree.
+func BuildTaskTree(tasks []*Task) []*Task {
+	byUID := make(map[string]*Task, len(tasks))
+	for _, t := range tasks {
+		byUID[t.UID] = t
+	}
+
+	parents := make(map[string]string, len(tasks))
+	for _, t := range tasks {
+		for i := range t.Relations {
+			rel := &t.Relations[i]
+			related, ok := byUID[rel.RelatedUID.String]
+			if !ok || related == t {
+				continue
+			}
+			switch {
+			case rel.IsParent():
+				if _, ok := parents[t.UID]; !ok {
+					parents[t.UID] = related.UID
+				}
+			case strings.EqualFold(rel.RelationshipType.String, relTypeChild):
+				if _, ok := parents[related.UID]; !ok {
+					parents[related.UID] = t.UID
+				}
+			}
+		}
+	}
+
+	children := make(map[string][]*Task, len(parents))
+	for _, t := range tasks {
+		if parentUID, ok := parents[t.UID]; ok {
+			children[parentUID] = append(children[parentUID], t)
+		}
+	}
+
+	var roots []*Task
+	attached := make(map[string]bool, len(tasks))
+	var attach func(t *Task)
+	attach = func(t *Task) {
+		attached[t.UID] = true
+		for _, sub := range children[t.UID] {
+			if !attached[sub.UID] {
+				t.Subtasks = append(t.Subtasks, sub)
+				attach(sub)
+			}
+		}
+	}
+	for _, t := range tasks {
+		if _, ok := parents[t.UID]; !ok {
+			roots = append(roots, t)
+			attach(t)
+		}
+	}
+	for _, t := range tasks {
+		if !attached[t.UID] {
+			roots = append(roots, t)
+			attach(t)
+		}
+	}
+	return roots
+}
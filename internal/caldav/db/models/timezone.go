@@ -0,0 +1,153 @@
+package models
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type Timezone struct {
+	TZID       string `json:"tzid"`
+	Definition string `json:"definition"`
+}
+
+func ScanTimezones(cal *ical.Calendar) []Timezone {
+	var timezones []Timezone
+
+	for _, child := range cal.Children {
+		if child.Name != ical.CompTimezone {
+			continue
+		}
+		tzid, err := child.Props.Text(ical.PropTimezoneID)
+		if err != nil || tzid == "" {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := ical.NewEncoder(&buf).Encode(&ical.Calendar{Component: child}); err != nil {
+			continue
+		}
+		timezones = append(timezones, Timezone{TZID: tzid, Definition: buf.String()})
+	}
+	return timezones
+}
+
+func (tz *Timezone) ToDomain() *ical.Component {
+	wrapped := "BEGIN:VCALENDAR\r\n" + tz.Definition + "END:VCALENDAR\r\n"
+
+	cal, err := ical.NewDecoder(strings.NewReader(wrapped)).Decode()
+	if err != nil || len(cal.Children) == 0 {
+		return nil
+	}
+	return cal.Children[0]
+}
+
+// Locations resolves TZID parameters to time locations. TZIDs that are not
+// known to the IANA database are resolved through their VTIMEZONE definition.
+type Locations map[string]*time.Location
+
+func NewLocations(timezones []*ical.Component) Locations {
+	locs := make(Locations, len(timezones))
+	for _, tz := range timezones {
+		if tz == nil || tz.Name != ical.CompTimezone {
+			continue
+		}
+		tzid, err := tz.Props.Text(ical.PropTimezoneID)
+		if err != nil || tzid == "" {
+			continue
+		}
+		locs[tzid] = resolveLocation(tzid, tz)
+	}
+	return locs
+}
+
+func (l Locations) Get(tzid string) *time.Location {
+	if loc, ok := l[tzid]; ok {
+		return loc
+	}
+	if loc, err := time.LoadLocation(tzid); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// In returns the UTC timestamp as a wall time of the given TZID.
+func (l Locations) In(value pgtype.Timestamp, tzid pgtype.Text) time.Time {
+	if !tzid.Valid {
+		return value.Time.UTC()
+	}
+	return value.Time.In(l.Get(tzid.String))
+}
+
+func resolveLocation(tzid string, tz *ical.Component) *time.Location {
+	if loc, err := time.LoadLocation(tzid); err == nil {
+		return loc
+	}
+	if name, err := tz.Props.Text("X-LIC-LOCATION"); err == nil && name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+
+	// Fall back to the offset of the latest STANDARD observance
+	var latest *ical.Component
+	for _, child := range tz.Children {
+		if child.Name != ical.CompTimezoneStandard {
+			continue
+		}
+		if latest == nil || !observanceStart(child).Before(observanceStart(latest)) {
+			latest = child
+		}
+	}
+	if latest == nil && len(tz.Children) > 0 {
+		latest = tz.Children[0]
+	}
+	if latest != nil {
+		if prop := latest.Props.Get(ical.PropTimezoneOffsetTo); prop != nil {
+			if offset, ok := parseUTCOffset(prop.Value); ok {
+				return time.FixedZone(tzid, offset)
+			}
+		}
+	}
+	return time.UTC
+}
+
+func observanceStart(observance *ical.Component) time.Time {
+	start, _ := observance.Props.DateTime(ical.PropDateTimeStart, time.UTC)
+	return start
+}
+
+func parseUTCOffset(value string) (int, bool) {
+	if len(value) != 5 && len(value) != 7 {
+		return 0, false
+	}
+
+	sign := 1
+	switch value[0] {
+	case '+':
+	case '-':
+		sign = -1
+	default:
+		return 0, false
+	}
+
+	hours, err := strconv.Atoi(value[1:3])
+	if err != nil {
+		return 0, false
+	}
+	minutes, err := strconv.Atoi(value[3:5])
+	if err != nil {
+		return 0, false
+	}
+	seconds := 0
+	if len(value) == 7 {
+		if seconds, err = strconv.Atoi(value[5:7]); err != nil {
+			return 0, false
+		}
+	}
+	return sign * (hours*3600 + minutes*60 + seconds), true
+}
@@ -0,0 +1,140 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+const (
+	davNamespace            = "DAV:"
+	caldavNamespace         = "urn:ietf:params:xml:ns:caldav"
+	calendarServerNamespace = "http://calendarserver.org/ns/"
+	appleICalNamespace      = "http://apple.com/ns/ical/"
+)
+
+var (
+	getETagName          = xml.Name{Space: davNamespace, Local: "getetag"}
+	getContentLengthName = xml.Name{Space: davNamespace, Local: "getcontentlength"}
+	getContentTypeName   = xml.Name{Space: davNamespace, Local: "getcontenttype"}
+	getLastModifiedName  = xml.Name{Space: davNamespace, Local: "getlastmodified"}
+	calendarDataName     = xml.Name{Space: caldavNamespace, Local: "calendar-data"}
+	syncTokenName        = xml.Name{Space: davNamespace, Local: "sync-token"}
+	getCTagName          = xml.Name{Space: calendarServerNamespace, Local: "getctag"}
+	hrefName             = xml.Name{Space: davNamespace, Local: "href"}
+)
+
+// multiStatus mirrors the go-webdav encoding of DAV:multistatus for the
+// reports and methods served by Handler itself. Unknown elements are kept, so
+// that go-webdav responses can be amended and encoded again.
+type multiStatus struct {
+	XMLName   xml.Name      `xml:"DAV: multistatus"`
+	Responses []response    `xml:"response"`
+	SyncToken string        `xml:"sync-token,omitempty"`
+	Any       []rawXMLValue `xml:",any"`
+}
+
+type response struct {
+	Href      string        `xml:"href"`
+	PropStats []propStat    `xml:"propstat,omitempty"`
+	Status    string        `xml:"status,omitempty"`
+	Any       []rawXMLValue `xml:",any"`
+}
+
+type propStat struct {
+	Prop   prop          `xml:"prop"`
+	Status string        `xml:"status"`
+	Any    []rawXMLValue `xml:",any"`
+}
+
+type prop struct {
+	Values []rawXMLValue `xml:",any"`
+}
+
+// rawXMLValue keeps the inner XML of an element as is. go-webdav declares the
+// namespace on every element, so the inner XML stays valid when moved around.
+type rawXMLValue struct {
+	XMLName xml.Name
+	Inner   []byte `xml:",innerxml"`
+}
+
+// newRawXMLValue returns an element holding the escaped text.
+func newRawXMLValue(name xml.Name, text string) rawXMLValue {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(text))
+	return rawXMLValue{XMLName: name, Inner: buf.Bytes()}
+}
+
+// encodeHref escapes a path for use as a DAV:href.
+func encodeHref(p string) string {
+	return (&url.URL{Path: p}).String()
+}
+
+func statusLine(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+func serveMultiStatus(w http.ResponseWriter, ms *multiStatus) error {
+	w.Header().Set("Content-Type", "application/xml; charset=\"utf-8\"")
+	w.WriteHeader(http.StatusMultiStatus)
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(ms)
+}
+
+// PreconditionError is a failed WebDAV precondition, served as a DAV:error
+// body naming the condition and, if set, the resource it failed on.
+type PreconditionError struct {
+	Code      int
+	Condition xml.Name
+	Href      string
+}
+
+func NewPreconditionError(code int, condition xml.Name) error {
+	return &PreconditionError{Code: code, Condition: condition}
+}
+
+func (err *PreconditionError) Error() string {
+	return fmt.Sprintf("%d %s: precondition %s failed", err.Code, http.StatusText(err.Code), err.Condition.Local)
+}
+
+type davError struct {
+	XMLName   xml.Name `xml:"DAV: error"`
+	Condition rawXMLValue
+}
+
+// statusCode returns the status code of errors created by webdav.NewHTTPError,
+// whose type is internal to go-webdav.
+func statusCode(err error) int {
+	for ; err != nil; err = errors.Unwrap(err) {
+		v := reflect.ValueOf(err)
+		if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		if code := v.Elem().FieldByName("Code"); code.IsValid() && code.CanInt() {
+			return int(code.Int())
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+func serveError(w http.ResponseWriter, err error) {
+	var precondErr *PreconditionError
+	if errors.As(err, &precondErr) {
+		w.Header().Set("Content-Type", "application/xml; charset=\"utf-8\"")
+		w.WriteHeader(precondErr.Code)
+		_, _ = w.Write([]byte(xml.Header))
+		condition := rawXMLValue{XMLName: precondErr.Condition}
+		if precondErr.Href != "" {
+			condition.Inner, _ = xml.Marshal(newRawXMLValue(hrefName, precondErr.Href))
+		}
+		_ = xml.NewEncoder(w).Encode(&davError{Condition: condition})
+		return
+	}
+	http.Error(w, err.Error(), statusCode(err))
+}
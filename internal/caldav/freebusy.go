@@ -0,0 +1,53 @@
+package caldav
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ceres919/go-webdav"
+	"github.com/emersion/go-ical"
+)
+
+var freeBusyQueryName = xml.Name{Space: caldavNamespace, Local: "free-busy-query"}
+
+// FreeBusyBackend is implemented by backends supporting the free-busy-query
+// REPORT.
+type FreeBusyBackend interface {
+	FreeBusyQuery(ctx context.Context, urlPath string, start, end time.Time) (*ical.Calendar, error)
+}
+
+type freeBusyQueryReq struct {
+	XMLName   xml.Name       `xml:"urn:ietf:params:xml:ns:caldav free-busy-query"`
+	TimeRange *timeRangeElem `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+}
+
+func (h *Handler) serveFreeBusyQuery(w http.ResponseWriter, r *http.Request, body []byte) error {
+	backend, ok := h.Backend.(FreeBusyBackend)
+	if !ok {
+		return webdav.NewHTTPError(http.StatusNotImplemented, nil)
+	}
+
+	var req freeBusyQueryReq
+	if err := xml.Unmarshal(body, &req); err != nil {
+		return webdav.NewHTTPError(http.StatusBadRequest, err)
+	}
+	tr, err := req.TimeRange.decode()
+	if err != nil {
+		return webdav.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if tr == nil || tr.Start.IsZero() || tr.End.IsZero() || !tr.End.After(tr.Start) {
+		return webdav.NewHTTPError(http.StatusBadRequest, fmt.Errorf("caldav: free-busy-query requires a time-range"))
+	}
+
+	cal, err := backend.FreeBusyQuery(r.Context(), r.URL.Path, tr.Start, tr.End)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", ical.MIMEType)
+	w.WriteHeader(http.StatusOK)
+	return ical.NewEncoder(w).Encode(cal)
+}
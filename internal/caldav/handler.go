@@ -0,0 +1,352 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ceres919/go-webdav"
+	"github.com/ceres919/go-webdav/caldav"
+	"github.com/emersion/go-ical"
+)
+
+// Preconditions of PUT, COPY and MOVE (RFC 4791 §5.3.2.1).
+var (
+	supportedCalendarDataName      = xml.Name{Space: caldavNamespace, Local: "supported-calendar-data"}
+	validCalendarDataName          = xml.Name{Space: caldavNamespace, Local: "valid-calendar-data"}
+	supportedCalendarComponentName = xml.Name{Space: caldavNamespace, Local: "supported-calendar-component"}
+	maxResourceSizeName            = xml.Name{Space: caldavNamespace, Local: "max-resource-size"}
+	noUIDConflictName              = xml.Name{Space: caldavNamespace, Local: "no-uid-conflict"}
+)
+
+type conditionsKey struct{}
+
+// Conditions holds the conditional request headers that go-webdav does not
+// pass down to the backend for every method.
+type Conditions struct {
+	IfMatch     webdav.ConditionalMatch
+	IfNoneMatch webdav.ConditionalMatch
+}
+
+func withConditions(ctx context.Context, c *Conditions) context.Context {
+	return context.WithValue(ctx, conditionsKey{}, c)
+}
+
+func conditionsFromContext(ctx context.Context) *Conditions {
+	c, ok := ctx.Value(conditionsKey{}).(*Conditions)
+	if !ok || c == nil {
+		return &Conditions{}
+	}
+	return c
+}
+
+type originKey struct{}
+
+// withOrigin keeps the scheme and host the request was sent to, so that the
+// backend can build absolute URLs.
+func withOrigin(ctx context.Context, r *http.Request) context.Context {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return context.WithValue(ctx, originKey{}, scheme+"://"+r.Host)
+}
+
+func originFromContext(ctx context.Context) string {
+	origin, _ := ctx.Value(originKey{}).(string)
+	return origin
+}
+
+type calendarBodyKey struct{}
+
+// withCalendarBody keeps the iCalendar data of a PUT request, for the backend
+// to store it as it was put.
+func withCalendarBody(ctx context.Context, body []byte) context.Context {
+	return context.WithValue(ctx, calendarBodyKey{}, body)
+}
+
+func calendarBodyFromContext(ctx context.Context) []byte {
+	body, _ := ctx.Value(calendarBodyKey{}).([]byte)
+	return body
+}
+
+type calendarDataKey struct{}
+
+// TimeRange is a CALDAV time-range given by the start and end attributes.
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// CalendarData holds the calendar-data modifiers of a REPORT request that
+// go-webdav does not decode.
+type CalendarData struct {
+	Expand             *TimeRange
+	LimitRecurrenceSet *TimeRange
+}
+
+type timeRangeElem struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}
+
+func (tr *timeRangeElem) decode() (*TimeRange, error) {
+	if tr == nil {
+		return nil, nil
+	}
+
+	var res TimeRange
+	var err error
+	if tr.Start != "" {
+		if res.Start, err = time.Parse("20060102T150405Z", tr.Start); err != nil {
+			return nil, fmt.Errorf("caldav: invalid time-range start: %w", err)
+		}
+	}
+	if tr.End != "" {
+		if res.End, err = time.Parse("20060102T150405Z", tr.End); err != nil {
+			return nil, fmt.Errorf("caldav: invalid time-range end: %w", err)
+		}
+	}
+	return &res, nil
+}
+
+type reportCalendarData struct {
+	Prop struct {
+		CalendarData *struct {
+			Expand             *timeRangeElem `xml:"urn:ietf:params:xml:ns:caldav expand"`
+			LimitRecurrenceSet *timeRangeElem `xml:"urn:ietf:params:xml:ns:caldav limit-recurrence-set"`
+		} `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+	} `xml:"DAV: prop"`
+}
+
+// decodeCalendarData reads the calendar-data modifiers from the REPORT body.
+func decodeCalendarData(body []byte) (*CalendarData, error) {
+	var report reportCalendarData
+	if err := xml.Unmarshal(body, &report); err != nil || report.Prop.CalendarData == nil {
+		return &CalendarData{}, nil
+	}
+
+	var data CalendarData
+	var err error
+	if data.Expand, err = report.Prop.CalendarData.Expand.decode(); err != nil {
+		return nil, err
+	}
+	if data.LimitRecurrenceSet, err = report.Prop.CalendarData.LimitRecurrenceSet.decode(); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func withCalendarData(ctx context.Context, data *CalendarData) context.Context {
+	return context.WithValue(ctx, calendarDataKey{}, data)
+}
+
+func calendarDataFromContext(ctx context.Context) *CalendarData {
+	data, ok := ctx.Value(calendarDataKey{}).(*CalendarData)
+	if !ok || data == nil {
+		return &CalendarData{}
+	}
+	return data
+}
+
+// Handler wraps caldav.Handler and exposes request details to the backend.
+type Handler struct {
+	caldav.Handler
+}
+
+func NewHandler(backend caldav.Backend) *Handler {
+	return &Handler{Handler: caldav.Handler{Backend: backend}}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r = r.WithContext(withConditions(r.Context(), &Conditions{
+		IfMatch:     webdav.ConditionalMatch(r.Header.Get("If-Match")),
+		IfNoneMatch: webdav.ConditionalMatch(r.Header.Get("If-None-Match")),
+	}))
+	r = r.WithContext(withOrigin(r.Context(), r))
+
+	switch r.Method {
+	case http.MethodOptions:
+		if _, ok := h.Backend.(AttachmentBackend); ok {
+			w.Header().Add("DAV", "calendar-managed-attachments")
+		}
+	case http.MethodGet, http.MethodHead:
+		if r.URL.Query().Has("managed-id") {
+			if err := h.serveAttachment(w, r); err != nil {
+				serveError(w, err)
+			}
+			return
+		}
+		if backend, ok := h.Backend.(RawDataBackend); ok {
+			served, err := serveRawData(w, r, backend)
+			if err != nil {
+				serveError(w, err)
+				return
+			}
+			if served {
+				return
+			}
+		}
+	case http.MethodPost:
+		if r.URL.Query().Has("action") {
+			if err := h.serveAttachmentAction(w, r); err != nil {
+				serveError(w, err)
+			}
+			return
+		}
+	case http.MethodPut:
+		if err := h.servePut(w, r); err != nil {
+			serveError(w, err)
+		}
+		return
+	case "COPY", "MOVE":
+		if err := h.serveCopyMove(w, r); err != nil {
+			serveError(w, err)
+		}
+		return
+	case "PROPPATCH":
+		if err := h.serveProppatch(w, r); err != nil {
+			serveError(w, err)
+		}
+		return
+	case "PROPFIND":
+		body, err := readBody(r)
+		if err != nil {
+			serveError(w, err)
+			return
+		}
+		if names := requestedCollectionProps(body); names != nil {
+			h.servePropfind(w, r, names)
+			return
+		}
+	case "REPORT":
+		body, err := readBody(r)
+		if err != nil {
+			serveError(w, err)
+			return
+		}
+		name := reportName(body)
+		switch name {
+		case syncCollectionName:
+			if err := h.serveSyncCollection(w, r, body); err != nil {
+				serveError(w, err)
+			}
+			return
+		case freeBusyQueryName:
+			if err := h.serveFreeBusyQuery(w, r, body); err != nil {
+				serveError(w, err)
+			}
+			return
+		}
+
+		data, err := decodeCalendarData(body)
+		if err != nil {
+			serveError(w, webdav.NewHTTPError(http.StatusBadRequest, err))
+			return
+		}
+		r = r.WithContext(withCalendarData(r.Context(), data))
+
+		if backend, ok := h.Backend.(MultigetBackend); ok && name == calendarMultigetName {
+			if err := serveMultiget(w, r, backend, body); err != nil {
+				serveError(w, err)
+			}
+			return
+		}
+	}
+	h.Handler.ServeHTTP(w, r)
+}
+
+// servePut stores the calendar object like go-webdav does, which reports
+// failed preconditions of PUT as plain 400 and 500 errors.
+func (h *Handler) servePut(w http.ResponseWriter, r *http.Request) error {
+	t, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || t != ical.MIMEType {
+		return NewPreconditionError(http.StatusForbidden, supportedCalendarDataName)
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return webdav.NewHTTPError(http.StatusBadRequest, err)
+	}
+	cal, err := ical.NewDecoder(bytes.NewReader(body)).Decode()
+	if err != nil {
+		return NewPreconditionError(http.StatusForbidden, validCalendarDataName)
+	}
+
+	ctx := withCalendarBody(r.Context(), canonicalCalendarBody(body))
+	obj, err := h.Backend.PutCalendarObject(ctx, r.URL.Path, cal, &caldav.PutCalendarObjectOptions{
+		IfNoneMatch: webdav.ConditionalMatch(r.Header.Get("If-None-Match")),
+		IfMatch:     webdav.ConditionalMatch(r.Header.Get("If-Match")),
+	})
+	if err != nil {
+		return err
+	}
+	if obj.ETag != "" {
+		w.Header().Set("ETag", strconv.Quote(obj.ETag))
+	}
+	if !obj.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", obj.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if obj.Path != "" {
+		w.Header().Set("Location", obj.Path)
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// canonicalCalendarBody returns the iCalendar data with its lines delimited by
+// CRLF (RFC 5545 §3.1), the last one included.
+func canonicalCalendarBody(body []byte) []byte {
+	lines := bytes.Split(bytes.TrimRight(body, "\r\n"), []byte("\n"))
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(bytes.TrimSuffix(line, []byte("\r")))
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes()
+}
+
+// RawDataBackend is implemented by backends keeping the iCalendar data of
+// objects as it was put. GetCalendarObjectData returns nil data for objects
+// which are not kept so, caldav.Handler serves them then.
+type RawDataBackend interface {
+	GetCalendarObjectData(ctx context.Context, objPath string) (*caldav.CalendarObject, []byte, error)
+}
+
+// serveRawData serves the kept data of the object as it is, go-webdav would
+// encode it anew. It reports whether the object has been served.
+func serveRawData(w http.ResponseWriter, r *http.Request, backend RawDataBackend) (bool, error) {
+	obj, data, err := backend.GetCalendarObjectData(r.Context(), r.URL.Path)
+	if err != nil || data == nil {
+		return false, err
+	}
+
+	w.Header().Set("Content-Type", ical.MIMEType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if obj.ETag != "" {
+		w.Header().Set("ETag", strconv.Quote(obj.ETag))
+	}
+	if !obj.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", obj.ModTime.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(data)
+	}
+	return true, nil
+}
+
+// readBody reads the request body and leaves it intact for go-webdav.
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, webdav.NewHTTPError(http.StatusBadRequest, err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,75 @@
+package caldav
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/ceres919/go-webdav"
+	"github.com/ceres919/go-webdav/caldav"
+)
+
+var calendarMultigetName = xml.Name{Space: caldavNamespace, Local: "calendar-multiget"}
+
+// objectPropNames are reported for DAV:allprop.
+var objectPropNames = []xml.Name{getETagName, getContentLengthName, getContentTypeName, getLastModifiedName}
+
+// MultigetBackend is implemented by backends loading the objects of a
+// calendar-multiget REPORT at once, go-webdav gets them one by one.
+type MultigetBackend interface {
+	MultiGetCalendarObjects(ctx context.Context, paths []string) ([]caldav.CalendarObject, error)
+}
+
+type calendarMultigetReq struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:caldav calendar-multiget"`
+	Prop    *prop    `xml:"DAV: prop"`
+	Hrefs   []string `xml:"DAV: href"`
+}
+
+func serveMultiget(w http.ResponseWriter, r *http.Request, backend MultigetBackend, body []byte) error {
+	var req calendarMultigetReq
+	if err := xml.Unmarshal(body, &req); err != nil {
+		return webdav.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	paths := make([]string, 0, len(req.Hrefs))
+	for _, href := range req.Hrefs {
+		u, err := url.Parse(strings.TrimSpace(href))
+		if err != nil {
+			return webdav.NewHTTPError(http.StatusBadRequest, err)
+		}
+		paths = append(paths, u.Path)
+	}
+	props := objectPropNames
+	if req.Prop != nil {
+		props = nil
+		for _, v := range req.Prop.Values {
+			props = append(props, v.XMLName)
+		}
+	}
+
+	objs, err := backend.MultiGetCalendarObjects(r.Context(), paths)
+	if err != nil {
+		return err
+	}
+	found := make(map[string]int, len(objs))
+	for i := range objs {
+		found[path.Clean(objs[i].Path)] = i
+	}
+
+	var ms multiStatus
+	for _, p := range paths {
+		i, ok := found[path.Clean(p)]
+		if !ok {
+			ms.Responses = append(ms.Responses, response{Href: encodeHref(p), Status: statusLine(http.StatusNotFound)})
+			continue
+		}
+		resp := objectResponse(&objs[i], props)
+		resp.Href = encodeHref(p)
+		ms.Responses = append(ms.Responses, resp)
+	}
+	return serveMultiStatus(w, &ms)
+}
@@ -0,0 +1,175 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+)
+
+// collectionProps are the live properties of calendar collections served on
+// top of go-webdav, which reports them as not found.
+var collectionProps = []xml.Name{
+	getCTagName,
+	syncTokenName,
+	calendarColorName,
+	calendarOrderName,
+	calendarTimezoneName,
+}
+
+// CollectionPropsBackend is implemented by backends providing live properties
+// of calendar collections that go-webdav does not know about.
+type CollectionPropsBackend interface {
+	// CollectionProps returns nil if urlPath is not a calendar collection.
+	CollectionProps(ctx context.Context, urlPath string) (map[xml.Name]string, error)
+}
+
+type propfindReq struct {
+	XMLName xml.Name `xml:"DAV: propfind"`
+	Prop    *prop    `xml:"prop"`
+}
+
+// requestedCollectionProps returns the collectionProps asked for by PROPFIND.
+func requestedCollectionProps(body []byte) []xml.Name {
+	var req propfindReq
+	if err := xml.Unmarshal(body, &req); err != nil || req.Prop == nil {
+		return nil
+	}
+
+	var names []xml.Name
+	for _, v := range req.Prop.Values {
+		for _, name := range collectionProps {
+			if v.XMLName == name {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// responseBuffer keeps a go-webdav response to be amended before sending.
+type responseBuffer struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (rb *responseBuffer) Header() http.Header {
+	return rb.header
+}
+
+func (rb *responseBuffer) Write(b []byte) (int, error) {
+	if rb.code == 0 {
+		rb.code = http.StatusOK
+	}
+	return rb.body.Write(b)
+}
+
+func (rb *responseBuffer) WriteHeader(code int) {
+	if rb.code == 0 {
+		rb.code = code
+	}
+}
+
+func (rb *responseBuffer) flush(w http.ResponseWriter, body []byte) {
+	for k, v := range rb.header {
+		if k != "Content-Length" {
+			w.Header()[k] = v
+		}
+	}
+	if rb.code == 0 {
+		rb.code = http.StatusOK
+	}
+	w.WriteHeader(rb.code)
+	_, _ = w.Write(body)
+}
+
+// servePropfind lets go-webdav answer the PROPFIND and fills in the
+// collection properties it could not find.
+func (h *Handler) servePropfind(w http.ResponseWriter, r *http.Request, names []xml.Name) {
+	backend, ok := h.Backend.(CollectionPropsBackend)
+	if !ok {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	rb := &responseBuffer{header: make(http.Header)}
+	h.Handler.ServeHTTP(rb, r)
+	if rb.code != http.StatusMultiStatus {
+		rb.flush(w, rb.body.Bytes())
+		return
+	}
+
+	var ms multiStatus
+	if err := xml.Unmarshal(rb.body.Bytes(), &ms); err != nil {
+		rb.flush(w, rb.body.Bytes())
+		return
+	}
+
+	for i := range ms.Responses {
+		values, err := backend.CollectionProps(r.Context(), ms.Responses[i].Href)
+		if err != nil || values == nil {
+			continue
+		}
+		ms.Responses[i].setProps(names, values)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(&ms); err != nil {
+		rb.flush(w, rb.body.Bytes())
+		return
+	}
+	rb.flush(w, buf.Bytes())
+}
+
+// setProps moves the properties from the 404 propstat to the 200 one.
+func (resp *response) setProps(names []xml.Name, values map[xml.Name]string) {
+	var found []rawXMLValue
+	for _, name := range names {
+		if value, ok := values[name]; ok {
+			found = append(found, newRawXMLValue(name, value))
+		}
+	}
+	if len(found) == 0 {
+		return
+	}
+
+	okStatus := statusLine(http.StatusOK)
+	propStats := resp.PropStats[:0]
+	for _, ps := range resp.PropStats {
+		if ps.Status != okStatus {
+			ps.Prop.Values = removeProps(ps.Prop.Values, found)
+			if len(ps.Prop.Values) == 0 {
+				continue
+			}
+		}
+		propStats = append(propStats, ps)
+	}
+	resp.PropStats = propStats
+
+	for i := range resp.PropStats {
+		if resp.PropStats[i].Status == okStatus {
+			resp.PropStats[i].Prop.Values = append(resp.PropStats[i].Prop.Values, found...)
+			return
+		}
+	}
+	resp.PropStats = append(resp.PropStats, propStat{Prop: prop{Values: found}, Status: okStatus})
+}
+
+func removeProps(values, props []rawXMLValue) []rawXMLValue {
+	res := values[:0]
+	for _, v := range values {
+		keep := true
+		for _, p := range props {
+			if v.XMLName == p.XMLName {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			res = append(res, v)
+		}
+	}
+	return res
+}
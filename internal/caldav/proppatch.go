@@ -0,0 +1,155 @@
+package caldav
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ceres919/go-webdav"
+	"github.com/emersion/go-ical"
+)
+
+var (
+	setName    = xml.Name{Space: davNamespace, Local: "set"}
+	removeName = xml.Name{Space: davNamespace, Local: "remove"}
+
+	displayNameName         = xml.Name{Space: davNamespace, Local: "displayname"}
+	calendarDescriptionName = xml.Name{Space: caldavNamespace, Local: "calendar-description"}
+	calendarTimezoneName    = xml.Name{Space: caldavNamespace, Local: "calendar-timezone"}
+	calendarColorName       = xml.Name{Space: appleICalNamespace, Local: "calendar-color"}
+	calendarOrderName       = xml.Name{Space: appleICalNamespace, Local: "calendar-order"}
+)
+
+var calendarColorRegexp = regexp.MustCompile(`^#[0-9A-Fa-f]{6}([0-9A-Fa-f]{2})?$`)
+
+// CollectionPatchBackend is implemented by backends allowing PROPPATCH of the
+// properties of calendar collections.
+type CollectionPatchBackend interface {
+	// PatchCollectionProps sets the properties to their value, those mapped
+	// to nil are removed.
+	PatchCollectionProps(ctx context.Context, urlPath string, props map[xml.Name]*string) error
+}
+
+type propertyUpdate struct {
+	XMLName xml.Name         `xml:"DAV: propertyupdate"`
+	Changes []propertyChange `xml:",any"`
+}
+
+// propertyChange is a DAV:set or DAV:remove instruction.
+type propertyChange struct {
+	XMLName xml.Name
+	Prop    struct {
+		Values []textValue `xml:",any"`
+	} `xml:"DAV: prop"`
+}
+
+type textValue struct {
+	XMLName xml.Name
+	Text    string `xml:",chardata"`
+}
+
+// serveProppatch applies the instructions in document order, either all of
+// them or none (RFC 4918 §9.2).
+func (h *Handler) serveProppatch(w http.ResponseWriter, r *http.Request) error {
+	backend, ok := h.Backend.(CollectionPatchBackend)
+	if !ok {
+		return webdav.NewHTTPError(http.StatusNotImplemented, nil)
+	}
+
+	var update propertyUpdate
+	if err := xml.NewDecoder(r.Body).Decode(&update); err != nil {
+		return webdav.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	var names []xml.Name
+	props := make(map[xml.Name]*string)
+	for _, change := range update.Changes {
+		if change.XMLName != setName && change.XMLName != removeName {
+			continue
+		}
+		for _, v := range change.Prop.Values {
+			if _, ok := props[v.XMLName]; !ok {
+				names = append(names, v.XMLName)
+			}
+			if change.XMLName == removeName {
+				props[v.XMLName] = nil
+			} else {
+				text := v.Text
+				props[v.XMLName] = &text
+			}
+		}
+	}
+
+	failed := make(map[xml.Name]int)
+	for _, name := range names {
+		if code := checkCollectionProp(name, props[name]); code != http.StatusOK {
+			failed[name] = code
+		}
+	}
+	if len(failed) == 0 {
+		if err := backend.PatchCollectionProps(r.Context(), r.URL.Path, props); err != nil {
+			return err
+		}
+	}
+
+	resp := response{Href: r.URL.Path}
+	byCode := make(map[int]int)
+	for _, name := range names {
+		code, ok := failed[name]
+		if !ok && len(failed) > 0 {
+			code = http.StatusFailedDependency
+		} else if !ok {
+			code = http.StatusOK
+		}
+		i, ok := byCode[code]
+		if !ok {
+			i = len(resp.PropStats)
+			byCode[code] = i
+			resp.PropStats = append(resp.PropStats, propStat{Status: statusLine(code)})
+		}
+		resp.PropStats[i].Prop.Values = append(resp.PropStats[i].Prop.Values, rawXMLValue{XMLName: name})
+	}
+	return serveMultiStatus(w, &multiStatus{Responses: []response{resp}})
+}
+
+// checkCollectionProp returns the status of setting the property to value,
+// or of removing it if value is nil.
+func checkCollectionProp(name xml.Name, value *string) int {
+	switch name {
+	case displayNameName:
+		if value == nil {
+			return http.StatusForbidden
+		}
+	case calendarDescriptionName:
+	case calendarColorName:
+		if value != nil && !calendarColorRegexp.MatchString(strings.TrimSpace(*value)) {
+			return http.StatusConflict
+		}
+	case calendarOrderName:
+		if value != nil {
+			if _, err := strconv.ParseInt(strings.TrimSpace(*value), 10, 32); err != nil {
+				return http.StatusConflict
+			}
+		}
+	case calendarTimezoneName:
+		if value != nil && !isTimezoneCalendar(*value) {
+			return http.StatusConflict
+		}
+	default:
+		return http.StatusForbidden
+	}
+	return http.StatusOK
+}
+
+// isTimezoneCalendar reports whether text is an iCalendar object with a
+// single VTIMEZONE, as required for calendar-timezone (RFC 4791 §5.2.2).
+func isTimezoneCalendar(text string) bool {
+	cal, err := ical.NewDecoder(strings.NewReader(strings.TrimSpace(text))).Decode()
+	if err != nil || len(cal.Children) != 1 {
+		return false
+	}
+	return cal.Children[0].Name == ical.CompTimezone
+}
@@ -0,0 +1,152 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+
+	"github.com/ceres919/go-webdav"
+	"github.com/ceres919/go-webdav/caldav"
+	"github.com/emersion/go-ical"
+)
+
+var (
+	syncCollectionName = xml.Name{Space: davNamespace, Local: "sync-collection"}
+	validSyncTokenName = xml.Name{Space: davNamespace, Local: "valid-sync-token"}
+)
+
+// SyncQuery is a RFC 6578 sync-collection request.
+type SyncQuery struct {
+	// SyncToken is empty on the initial synchronization.
+	SyncToken string
+	// Limit is the maximum number of members to report, 0 if unlimited.
+	Limit int
+	// WithData is set if the calendar data of the changed members is requested.
+	WithData bool
+}
+
+// SyncResponse lists the collection members changed since the sync token.
+type SyncResponse struct {
+	SyncToken string
+	Changed   []caldav.CalendarObject
+	Removed   []string
+	Truncated bool
+}
+
+// SyncBackend is implemented by backends supporting the sync-collection REPORT.
+type SyncBackend interface {
+	SyncCollection(ctx context.Context, urlPath string, query *SyncQuery) (*SyncResponse, error)
+}
+
+type syncCollectionReq struct {
+	XMLName   xml.Name `xml:"DAV: sync-collection"`
+	SyncToken string   `xml:"sync-token"`
+	SyncLevel string   `xml:"sync-level"`
+	Limit     *struct {
+		NResults int `xml:"nresults"`
+	} `xml:"limit"`
+	Prop *prop `xml:"prop"`
+}
+
+// reportName returns the name of the root element of a REPORT body.
+func reportName(body []byte) xml.Name {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return xml.Name{}
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name
+		}
+	}
+}
+
+func (h *Handler) serveSyncCollection(w http.ResponseWriter, r *http.Request, body []byte) error {
+	backend, ok := h.Backend.(SyncBackend)
+	if !ok {
+		return webdav.NewHTTPError(http.StatusNotImplemented, nil)
+	}
+
+	var req syncCollectionReq
+	if err := xml.Unmarshal(body, &req); err != nil {
+		return webdav.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	query := SyncQuery{SyncToken: req.SyncToken}
+	if req.Limit != nil {
+		query.Limit = req.Limit.NResults
+	}
+	var props []xml.Name
+	if req.Prop != nil {
+		for _, v := range req.Prop.Values {
+			props = append(props, v.XMLName)
+			if v.XMLName == calendarDataName {
+				query.WithData = true
+			}
+		}
+	}
+
+	resp, err := backend.SyncCollection(r.Context(), r.URL.Path, &query)
+	if err != nil {
+		return err
+	}
+
+	ms := multiStatus{SyncToken: resp.SyncToken}
+	for i := range resp.Changed {
+		ms.Responses = append(ms.Responses, objectResponse(&resp.Changed[i], props))
+	}
+	for _, href := range resp.Removed {
+		ms.Responses = append(ms.Responses, response{
+			Href:   encodeHref(href),
+			Status: statusLine(http.StatusNotFound),
+		})
+	}
+	if resp.Truncated {
+		ms.Responses = append(ms.Responses, response{
+			Href:   r.URL.Path,
+			Status: statusLine(http.StatusInsufficientStorage),
+		})
+	}
+	return serveMultiStatus(w, &ms)
+}
+
+// objectResponse renders the requested properties of a calendar object.
+func objectResponse(co *caldav.CalendarObject, props []xml.Name) response {
+	var found, notFound prop
+	for _, name := range props {
+		var text string
+		switch name {
+		case getETagName:
+			text = strconv.Quote(co.ETag)
+		case getContentLengthName:
+			text = strconv.FormatInt(co.ContentLength, 10)
+		case getContentTypeName:
+			text = ical.MIMEType
+		case getLastModifiedName:
+			text = co.ModTime.UTC().Format(http.TimeFormat)
+		case calendarDataName:
+			var buf bytes.Buffer
+			if co.Data == nil || ical.NewEncoder(&buf).Encode(co.Data) != nil {
+				notFound.Values = append(notFound.Values, rawXMLValue{XMLName: name})
+				continue
+			}
+			text = buf.String()
+		default:
+			notFound.Values = append(notFound.Values, rawXMLValue{XMLName: name})
+			continue
+		}
+		found.Values = append(found.Values, newRawXMLValue(name, text))
+	}
+
+	resp := response{Href: encodeHref(co.Path)}
+	if len(found.Values) > 0 || len(notFound.Values) == 0 {
+		resp.PropStats = append(resp.PropStats, propStat{Prop: found, Status: statusLine(http.StatusOK)})
+	}
+	if len(notFound.Values) > 0 {
+		resp.PropStats = append(resp.PropStats, propStat{Prop: notFound, Status: statusLine(http.StatusNotFound)})
+	}
+	return resp
+}
@@ -5,11 +5,13 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"net/http"
 	"path"
 	"strings"
 	"time"
 
 	"github.com/Raimguzhinov/dav-go/internal/usecase/etag"
+	"github.com/Raimguzhinov/dav-go/pkg/postgres"
 	"github.com/ceres919/go-webdav"
 	"github.com/ceres919/go-webdav/carddav"
 	"github.com/emersion/go-vcard"
@@ -55,7 +57,7 @@ func (s *carddavServer) CreateDefaultAddressBook(ctx context.Context) (*carddav.
 	}
 	err = s.repo.CreateFolder(ctx, homeSetPath, &ab)
 	if err != nil {
-		return nil, err
+		return nil, postgres.HTTPError(err)
 	}
 	return &ab, nil
 }
@@ -68,7 +70,7 @@ func (s *carddavServer) ListAddressBooks(ctx context.Context) ([]carddav.Address
 
 	addressbooks, err := s.repo.FindFolders(ctx, homeSetPath)
 	if err != nil {
-		return nil, err
+		return nil, postgres.HTTPError(err)
 	}
 
 	if len(addressbooks) == 0 {
@@ -92,7 +94,7 @@ func (s *carddavServer) GetAddressBook(ctx context.Context, urlPath string) (*ca
 
 	addressbooks, err := s.repo.FindFolders(ctx, homeSetPath)
 	if err != nil {
-		return nil, err
+		return nil, postgres.HTTPError(err)
 	}
 
 	for _, addressbook := range addressbooks {
@@ -100,7 +102,7 @@ func (s *carddavServer) GetAddressBook(ctx context.Context, urlPath string) (*ca
 			return &addressbook, nil
 		}
 	}
-	return nil, fmt.Errorf("addressbook for path: %s not found", urlPath)
+	return nil, webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("addressbook for path: %s not found", urlPath))
 }
 
 func (s *carddavServer) CreateAddressBook(ctx context.Context, addressBook *carddav.AddressBook) error {
@@ -111,7 +113,7 @@ func (s *carddavServer) CreateAddressBook(ctx context.Context, addressBook *card
 
 	err = s.repo.CreateFolder(ctx, homeSetPath, addressBook)
 	if err != nil {
-		return err
+		return postgres.HTTPError(err)
 	}
 	return nil
 }
@@ -132,13 +134,13 @@ func (s *carddavServer) DeleteAddressBook(ctx context.Context, path string) erro
 func (s *carddavServer) GetAddressObject(ctx context.Context, urlPath string, req *carddav.AddressDataRequest) (*carddav.AddressObject, error) {
 	homeSetPath, err := s.AddressBookHomeSetPath(ctx)
 	if err != nil {
-		return nil, err
+		return nil, postgres.HTTPError(err)
 	}
 
 	splitPath := strings.Split(strings.TrimPrefix(urlPath, homeSetPath), "/")
 	addressObjects, err := s.repo.FindAddressObjects(ctx, homeSetPath, splitPath[0])
 	if err != nil {
-		return nil, err
+		return nil, postgres.HTTPError(err)
 	}
 
 	for i := range addressObjects {
@@ -147,7 +149,7 @@ func (s *carddavServer) GetAddressObject(ctx context.Context, urlPath string, re
 		}
 	}
 
-	return nil, fmt.Errorf("address object for path: %s not found", urlPath)
+	return nil, webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("address object for path: %s not found", urlPath))
 }
 
 func (s *carddavServer) ListAddressObjects(ctx context.Context, urlPath string, req *carddav.AddressDataRequest) ([]carddav.AddressObject, error) {
@@ -159,7 +161,7 @@ func (s *carddavServer) ListAddressObjects(ctx context.Context, urlPath string,
 	abUID := path.Clean(strings.TrimPrefix(urlPath, homeSetPath))
 	addressObjects, err := s.repo.FindAddressObjects(ctx, homeSetPath, abUID)
 	if err != nil {
-		return nil, err
+		return nil, postgres.HTTPError(err)
 	}
 
 	return addressObjects, nil
@@ -190,7 +192,7 @@ func (s *carddavServer) PutAddressObject(ctx context.Context, urlPath string, ca
 
 	err = s.repo.PutAddressObject(ctx, homeSetPath, &ao, opts)
 	if err != nil {
-		return nil, err
+		return nil, postgres.HTTPError(err)
 	}
 	return &ao, nil
 }
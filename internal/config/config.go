@@ -22,11 +22,12 @@ type (
 	}
 
 	App struct {
-		Env           string `yaml:"env"            env-default:"local"`
-		Name          string `yaml:"name"           env-default:"dav-go"`
-		Version       string `yaml:"version"        env-required:"true"      env:"APP_VERSION" `
-		CalDAVPrefix  string `yaml:"caldav_prefix"  env-default:"calendars"`
-		CardDAVPrefix string `yaml:"carddav_prefix" env-default:"contacts"`
+		Env              string `yaml:"env"                env-default:"local"`
+		Name             string `yaml:"name"               env-default:"dav-go"`
+		Version          string `yaml:"version"            env-required:"true"      env:"APP_VERSION" `
+		CalDAVPrefix     string `yaml:"caldav_prefix"      env-default:"calendars"`
+		CardDAVPrefix    string `yaml:"carddav_prefix"     env-default:"contacts"`
+		CalDAVRawStorage bool   `yaml:"caldav_raw_storage" env-default:"false"      env:"CALDAV_RAW_STORAGE"`
 	}
 
 	HTTP struct {
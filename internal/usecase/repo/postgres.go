@@ -15,6 +15,7 @@ import (
 func NewBackends(
 	upBackend webdav.UserPrincipalBackend,
 	caldavPrefix, carddavPrefix string,
+	caldavRawStorage bool,
 	pg *postgres.Postgres,
 	logger *logger.Logger,
 ) (caldav.Backend, carddav.Backend, error) {
@@ -22,6 +23,7 @@ func NewBackends(
 		upBackend,
 		caldavPrefix,
 		caldavDB.NewRepository(pg, logger),
+		caldavRawStorage,
 	)
 	if err != nil {
 		return nil, nil, err
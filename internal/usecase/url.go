@@ -13,21 +13,24 @@ import (
 )
 
 type Url struct {
-	storageURL    string
-	caldavPrefix  string
-	carddavPrefix string
-	upBackend     webdav.UserPrincipalBackend
+	storageURL       string
+	caldavPrefix     string
+	carddavPrefix    string
+	caldavRawStorage bool
+	upBackend        webdav.UserPrincipalBackend
 }
 
 func NewURL(
 	storageURL, caldavPrefix, carddavPrefix string,
+	caldavRawStorage bool,
 	upBackend webdav.UserPrincipalBackend,
 ) *Url {
 	return &Url{
-		storageURL:    storageURL,
-		caldavPrefix:  caldavPrefix,
-		carddavPrefix: carddavPrefix,
-		upBackend:     upBackend,
+		storageURL:       storageURL,
+		caldavPrefix:     caldavPrefix,
+		carddavPrefix:    carddavPrefix,
+		caldavRawStorage: caldavRawStorage,
+		upBackend:        upBackend,
 	}
 }
 
@@ -51,6 +54,7 @@ func NewFromURL(
 			useCaseUrl.upBackend,
 			useCaseUrl.caldavPrefix,
 			useCaseUrl.carddavPrefix,
+			useCaseUrl.caldavRawStorage,
 			pg,
 			logger,
 		)
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE caldav.calendar_folder
+    DROP COLUMN IF EXISTS is_default;
+
+COMMIT;
@@ -0,0 +1,22 @@
+BEGIN;
+
+ALTER TABLE caldav.calendar_folder
+    ADD COLUMN IF NOT EXISTS is_default BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- The first calendar owned by a principal is its default one. Calendars
+-- without an owner yet all go to one principal in 000003_calendar_access,
+-- the first of them is its default one
+UPDATE caldav.calendar_folder
+SET is_default = TRUE
+WHERE id IN (SELECT MIN(calendar_folder_id)
+             FROM caldav.access
+             WHERE owner = B'1'
+             GROUP BY user_id)
+   OR id = (SELECT MIN(f.id)
+            FROM caldav.calendar_folder f
+            WHERE NOT EXISTS (SELECT
+                              FROM caldav.access a
+                              WHERE a.calendar_folder_id = f.id
+                                AND a.owner = B'1'));
+
+COMMIT;
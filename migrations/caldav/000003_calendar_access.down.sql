@@ -0,0 +1,13 @@
+BEGIN;
+
+DROP PROCEDURE IF EXISTS caldav.grant_unowned_folders(VARCHAR);
+
+DROP INDEX IF EXISTS caldav.access_user_id_idx;
+
+ALTER TABLE caldav.access
+    DROP CONSTRAINT IF EXISTS access_calendar_folder_id_user_id_key;
+
+ALTER TABLE caldav.access
+    ADD CONSTRAINT access_calendar_folder_id_key UNIQUE (calendar_folder_id);
+
+COMMIT;
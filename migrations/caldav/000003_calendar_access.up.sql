@@ -0,0 +1,30 @@
+BEGIN;
+
+ALTER TABLE caldav.access
+    DROP CONSTRAINT IF EXISTS access_calendar_folder_id_key;
+
+ALTER TABLE caldav.access
+    ADD CONSTRAINT access_calendar_folder_id_user_id_key UNIQUE (calendar_folder_id, user_id);
+
+-- Calendars without any access row are seen by nobody, they are given to
+-- the principal as its own
+CREATE OR REPLACE PROCEDURE caldav.grant_unowned_folders(
+    IN p_user_id VARCHAR(50)
+)
+    LANGUAGE sql AS
+$$
+INSERT INTO caldav.access (calendar_folder_id, user_id, owner, read, write)
+SELECT f.id, p_user_id, B'1', B'1', B'1'
+FROM caldav.calendar_folder f
+WHERE NOT EXISTS (SELECT
+                  FROM caldav.access a
+                  WHERE a.calendar_folder_id = f.id);
+$$;
+
+-- Calendars stored before access was recorded go to the principal set by
+-- caldav.owner, to the one of the default configuration otherwise
+CALL caldav.grant_unowned_folders(COALESCE(NULLIF(current_setting('caldav.owner', TRUE), ''), 'admin'));
+
+CREATE INDEX IF NOT EXISTS access_user_id_idx ON caldav.access (user_id);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TABLE IF EXISTS caldav.calendar_timezone;
+
+ALTER TABLE caldav.event_component
+    DROP COLUMN IF EXISTS start_tzid,
+    DROP COLUMN IF EXISTS end_tzid;
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+ALTER TABLE caldav.event_component
+    ADD COLUMN IF NOT EXISTS start_tzid VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS end_tzid   VARCHAR(255);
+
+CREATE TABLE IF NOT EXISTS caldav.calendar_timezone
+(
+    calendar_file_uid UUID REFERENCES caldav.calendar_file (uid) ON DELETE CASCADE,
+    tzid              VARCHAR(255) NOT NULL,
+    definition        TEXT         NOT NULL,
+    PRIMARY KEY (calendar_file_uid, tzid)
+);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS caldav.event_component_time_range_idx;
+DROP INDEX IF EXISTS caldav.calendar_file_folder_id_idx;
+
+COMMIT;
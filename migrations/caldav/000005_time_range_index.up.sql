@@ -0,0 +1,9 @@
+BEGIN;
+
+CREATE INDEX IF NOT EXISTS calendar_file_folder_id_idx
+    ON caldav.calendar_file (calendar_folder_id);
+
+CREATE INDEX IF NOT EXISTS event_component_time_range_idx
+    ON caldav.event_component (calendar_file_uid, component_type, start_date, end_date);
+
+COMMIT;
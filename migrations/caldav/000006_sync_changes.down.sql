@@ -0,0 +1,11 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS calendar_file_change_trigger ON caldav.calendar_file;
+DROP FUNCTION IF EXISTS caldav.calendar_file_change_trigger_fnc();
+DROP FUNCTION IF EXISTS caldav.log_calendar_change(BIGINT, UUID, BIT);
+DROP TABLE IF EXISTS caldav.calendar_change;
+
+ALTER TABLE caldav.calendar_folder
+    DROP COLUMN IF EXISTS sync_revision;
+
+COMMIT;
@@ -0,0 +1,69 @@
+BEGIN;
+
+ALTER TABLE caldav.calendar_folder
+    ADD COLUMN IF NOT EXISTS sync_revision BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS caldav.calendar_change
+(
+    id                 BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    calendar_folder_id BIGINT REFERENCES caldav.calendar_folder (id) ON DELETE CASCADE,
+    calendar_file_uid  UUID      NOT NULL, -- no reference, deleted files stay in the log
+    revision           BIGINT    NOT NULL,
+    deleted            BIT       NOT NULL,
+    changed_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (calendar_folder_id, revision)
+);
+
+CREATE OR REPLACE FUNCTION caldav.log_calendar_change(
+    p_calendar_folder_id BIGINT,
+    p_calendar_file_uid UUID,
+    p_deleted BIT
+)
+    RETURNS VOID
+    LANGUAGE plpgsql AS
+$$
+DECLARE
+    v_revision BIGINT;
+BEGIN
+    UPDATE caldav.calendar_folder
+    SET sync_revision = sync_revision + 1
+    WHERE id = p_calendar_folder_id
+    RETURNING sync_revision INTO v_revision;
+
+    -- The folder itself is being deleted
+    IF NOT FOUND THEN
+        RETURN;
+    END IF;
+
+    INSERT INTO caldav.calendar_change (calendar_folder_id, calendar_file_uid, revision, deleted)
+    VALUES (p_calendar_folder_id, p_calendar_file_uid, v_revision, p_deleted);
+
+    -- Keep the last 10000 changes, older sync tokens expire
+    DELETE
+    FROM caldav.calendar_change
+    WHERE calendar_folder_id = p_calendar_folder_id
+      AND revision <= v_revision - 10000;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION caldav.calendar_file_change_trigger_fnc()
+    RETURNS trigger AS
+$$
+BEGIN
+    IF TG_OP = 'DELETE' OR TG_OP = 'UPDATE' AND OLD.calendar_folder_id IS DISTINCT FROM NEW.calendar_folder_id THEN
+        PERFORM caldav.log_calendar_change(OLD.calendar_folder_id, OLD.uid, B'1');
+    END IF;
+    IF TG_OP = 'INSERT' OR TG_OP = 'UPDATE' THEN
+        PERFORM caldav.log_calendar_change(NEW.calendar_folder_id, NEW.uid, B'0');
+    END IF;
+    RETURN NULL;
+END;
+$$
+    LANGUAGE 'plpgsql';
+CREATE TRIGGER calendar_file_change_trigger
+    AFTER INSERT OR UPDATE OR DELETE
+    ON caldav.calendar_file
+    FOR EACH ROW
+EXECUTE PROCEDURE caldav.calendar_file_change_trigger_fnc();
+
+COMMIT;
@@ -0,0 +1,19 @@
+BEGIN;
+
+DROP INDEX IF EXISTS caldav.attendee_event_component_id_idx;
+
+DELETE FROM caldav.attendee a
+    USING caldav.attendee b
+    WHERE a.event_component_id = b.event_component_id
+      AND a.id > b.id;
+
+ALTER TABLE caldav.attendee
+    ALTER COLUMN common_name TYPE VARCHAR(50) USING left(common_name, 50),
+    ALTER COLUMN sent_by TYPE VARCHAR(50) USING left(sent_by, 50),
+    ALTER COLUMN delegated_from TYPE VARCHAR(50) USING left(delegated_from, 50),
+    ALTER COLUMN delegated_to TYPE VARCHAR(50) USING left(delegated_to, 50);
+
+ALTER TABLE caldav.attendee
+    ADD CONSTRAINT attendee_event_component_id_key UNIQUE (event_component_id);
+
+COMMIT;
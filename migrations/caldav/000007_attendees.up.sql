@@ -0,0 +1,15 @@
+BEGIN;
+
+ALTER TABLE caldav.attendee
+    DROP CONSTRAINT IF EXISTS attendee_event_component_id_key;
+
+ALTER TABLE caldav.attendee
+    ALTER COLUMN common_name TYPE TEXT,
+    ALTER COLUMN sent_by TYPE TEXT,
+    ALTER COLUMN delegated_from TYPE TEXT,
+    ALTER COLUMN delegated_to TYPE TEXT;
+
+CREATE INDEX IF NOT EXISTS attendee_event_component_id_idx
+    ON caldav.attendee (event_component_id);
+
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN;
+
+DROP INDEX IF EXISTS caldav.alarm_event_component_id_idx;
+
+DELETE FROM caldav.alarm a
+    USING caldav.alarm b
+    WHERE a.event_component_id = b.event_component_id
+      AND a.id > b.id;
+
+ALTER TABLE caldav.alarm
+    DROP COLUMN IF EXISTS trigger_related,
+    ALTER COLUMN duration TYPE TIMESTAMP USING NULL,
+    ALTER COLUMN trigger TYPE VARCHAR(15) USING left(trigger, 15);
+
+ALTER TABLE caldav.alarm
+    ADD CONSTRAINT alarm_event_component_id_key UNIQUE (event_component_id);
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+ALTER TABLE caldav.alarm
+    DROP CONSTRAINT IF EXISTS alarm_event_component_id_key;
+
+ALTER TABLE caldav.alarm
+    ALTER COLUMN trigger TYPE VARCHAR(32),
+    ALTER COLUMN duration TYPE VARCHAR(32) USING NULL,
+    ADD COLUMN IF NOT EXISTS trigger_related VARCHAR(5);
+
+CREATE INDEX IF NOT EXISTS alarm_event_component_id_idx
+    ON caldav.alarm (event_component_id);
+
+COMMIT;
@@ -0,0 +1,21 @@
+BEGIN;
+
+DROP INDEX IF EXISTS caldav.attachment_managed_id_idx;
+DROP INDEX IF EXISTS caldav.attachment_event_component_id_idx;
+
+ALTER TABLE caldav.attachment
+    DROP CONSTRAINT IF EXISTS attachment_managed_id_key;
+
+DELETE FROM caldav.attachment a
+    USING caldav.attachment b
+    WHERE a.event_component_id = b.event_component_id
+      AND a.id > b.id;
+
+ALTER TABLE caldav.attachment
+    DROP COLUMN IF EXISTS filename,
+    DROP COLUMN IF EXISTS managed_id;
+
+ALTER TABLE caldav.attachment
+    ADD CONSTRAINT attachment_event_component_id_key UNIQUE (event_component_id);
+
+COMMIT;
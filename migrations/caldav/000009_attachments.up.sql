@@ -0,0 +1,20 @@
+BEGIN;
+
+ALTER TABLE caldav.attachment
+    DROP CONSTRAINT IF EXISTS attachment_event_component_id_key;
+
+ALTER TABLE caldav.attachment
+    ADD COLUMN IF NOT EXISTS managed_id UUID,
+    ADD COLUMN IF NOT EXISTS filename   VARCHAR(255);
+
+ALTER TABLE caldav.attachment
+    ADD CONSTRAINT attachment_managed_id_key UNIQUE (event_component_id, managed_id);
+
+CREATE INDEX IF NOT EXISTS attachment_event_component_id_idx
+    ON caldav.attachment (event_component_id);
+
+CREATE INDEX IF NOT EXISTS attachment_managed_id_idx
+    ON caldav.attachment (managed_id)
+    WHERE managed_id IS NOT NULL;
+
+COMMIT;
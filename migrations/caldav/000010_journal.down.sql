@@ -0,0 +1,11 @@
+BEGIN;
+
+DELETE FROM caldav.event_component
+WHERE component_type = 'VJOURNAL';
+
+ALTER TABLE caldav.event_component
+    DROP COLUMN IF EXISTS journal_descriptions,
+    ALTER COLUMN component_type TYPE BIT
+        USING (CASE WHEN component_type = 'VEVENT' THEN B'1' ELSE B'0' END);
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE caldav.event_component
+    ALTER COLUMN component_type TYPE caldav.calendar_type
+        USING (CASE WHEN component_type = B'1' THEN 'VEVENT' ELSE 'VTODO' END)::caldav.calendar_type,
+    ADD COLUMN IF NOT EXISTS journal_descriptions TEXT[];
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP TABLE IF EXISTS caldav.relation;
+
+ALTER TABLE caldav.event_component
+    DROP COLUMN IF EXISTS todo_due_tzid,
+    DROP COLUMN IF EXISTS todo_due,
+    ALTER COLUMN todo_completed TYPE DATE USING todo_completed::date;
+
+COMMIT;
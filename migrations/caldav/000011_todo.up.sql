@@ -0,0 +1,22 @@
+BEGIN;
+
+ALTER TABLE caldav.event_component
+    ALTER COLUMN todo_completed TYPE TIMESTAMP USING todo_completed::timestamp,
+    ADD COLUMN IF NOT EXISTS todo_due      TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS todo_due_tzid VARCHAR(255);
+
+CREATE TABLE IF NOT EXISTS caldav.relation
+(
+    id                 BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    event_component_id BIGINT REFERENCES caldav.event_component (id) ON DELETE CASCADE,
+    related_uid        VARCHAR(255) NOT NULL,
+    relationship_type  VARCHAR(15)
+);
+
+CREATE INDEX IF NOT EXISTS relation_event_component_id_idx
+    ON caldav.relation (event_component_id);
+
+CREATE INDEX IF NOT EXISTS relation_related_uid_idx
+    ON caldav.relation (related_uid);
+
+COMMIT;
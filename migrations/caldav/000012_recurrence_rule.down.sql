@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE caldav.recurrence
+    DROP COLUMN IF EXISTS rule,
+    DROP COLUMN IF EXISTS frequency;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE caldav.recurrence
+    ADD COLUMN IF NOT EXISTS frequency VARCHAR(10),
+    ADD COLUMN IF NOT EXISTS rule      TEXT;
+
+COMMIT;
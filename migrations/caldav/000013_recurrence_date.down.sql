@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS caldav.recurrence_date;
+
+COMMIT;
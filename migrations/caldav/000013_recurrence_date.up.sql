@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS caldav.recurrence_date
+(
+    id            BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    recurrence_id BIGINT REFERENCES caldav.recurrence (id) ON DELETE CASCADE,
+    value         TIMESTAMP   NOT NULL,
+    end_date      TIMESTAMP,
+    period        VARCHAR(32),
+    tzid          VARCHAR(255),
+    value_type    VARCHAR(10) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS recurrence_date_recurrence_id_idx
+    ON caldav.recurrence_date (recurrence_id);
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS caldav.property
+(
+    id                 BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    event_component_id BIGINT REFERENCES caldav.event_component (id) ON DELETE CASCADE,
+    name               VARCHAR(255) NOT NULL,
+    params             JSONB,
+    value              TEXT         NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS property_event_component_id_idx
+    ON caldav.property (event_component_id);
+
+COMMIT;
@@ -0,0 +1,70 @@
+BEGIN;
+
+CREATE OR REPLACE PROCEDURE caldav.create_or_update_calendar_file(
+    IN p_calendar_uid UUID,
+    IN p_calendar_folder_type caldav.calendar_type,
+    IN p_calendar_folder_id BIGINT,
+    IN p_etag VARCHAR(40),
+    IN p_want_etag VARCHAR(40),
+    IN p_modified_at TIMESTAMP,
+    IN p_size INT,
+    IN p_version VARCHAR(5),
+    IN p_product VARCHAR(100),
+    IN p_if_none_match BOOLEAN DEFAULT FALSE,
+    IN p_if_match BOOLEAN DEFAULT FALSE,
+    IN p_scale VARCHAR(30) DEFAULT 'GREGORIAN',
+    IN p_method VARCHAR(30) DEFAULT NULL
+)
+    LANGUAGE plpgsql AS
+$$
+DECLARE
+    v_support_folder_id BIGINT;
+    v_current_etag      VARCHAR(40);
+BEGIN
+    SELECT f.id
+    INTO
+        v_support_folder_id
+    FROM caldav.calendar_folder f
+    WHERE f.id = p_calendar_folder_id
+      AND p_calendar_folder_type = ANY (f.types);
+
+    IF v_support_folder_id IS DISTINCT FROM p_calendar_folder_id THEN
+        RAISE EXCEPTION 'Invalid folder type provided for folder: %', p_calendar_folder_id;
+    END IF;
+
+    SELECT etag
+    INTO
+        v_current_etag
+    FROM caldav.calendar_file
+    WHERE uid = p_calendar_uid;
+
+    IF FOUND THEN
+        IF p_if_none_match THEN
+            RAISE EXCEPTION 'Precondition failed: If-None-Match header is set and resource exists';
+        END IF;
+
+        IF p_if_match AND v_current_etag IS DISTINCT FROM p_want_etag THEN
+            RAISE EXCEPTION 'Precondition failed: If-Match header is set and ETag does not match';
+        END IF;
+
+        UPDATE
+            caldav.calendar_file
+        SET etag        = p_etag,
+            modified_at = p_modified_at,
+            size        = p_size
+        WHERE uid = p_calendar_uid;
+    ELSE
+        IF p_if_match THEN
+            RAISE EXCEPTION 'Precondition failed: If-Match header is set and resource does not exist';
+        END IF;
+
+        INSERT INTO caldav.calendar_file (uid, calendar_folder_id, etag, created_at, modified_at, size)
+        VALUES (p_calendar_uid, p_calendar_folder_id, p_etag, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, p_size);
+
+        INSERT INTO caldav.calendar_property (calendar_file_uid, version, product, scale, method)
+        VALUES (p_calendar_uid, p_version, p_product, p_scale, p_method);
+    END IF;
+END;
+$$;
+
+COMMIT;
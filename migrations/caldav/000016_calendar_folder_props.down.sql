@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE caldav.calendar_folder
+    DROP COLUMN IF EXISTS color,
+    DROP COLUMN IF EXISTS sort_order,
+    DROP COLUMN IF EXISTS timezone;
+
+COMMIT;
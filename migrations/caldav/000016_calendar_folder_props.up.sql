@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE caldav.calendar_folder
+    ADD COLUMN IF NOT EXISTS color      VARCHAR(9) DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS sort_order INT        DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS timezone   TEXT       DEFAULT NULL;
+
+COMMIT;
@@ -0,0 +1,154 @@
+BEGIN;
+
+DROP PROCEDURE IF EXISTS caldav.create_or_update_calendar_file(
+    UUID, TEXT, TEXT, caldav.calendar_type, BIGINT, VARCHAR, VARCHAR, TIMESTAMP, INT, VARCHAR, VARCHAR,
+    BOOLEAN, BOOLEAN, VARCHAR, VARCHAR
+    );
+
+DROP TRIGGER IF EXISTS calendar_file_change_trigger ON caldav.calendar_file;
+DROP FUNCTION IF EXISTS caldav.calendar_file_change_trigger_fnc();
+DROP FUNCTION IF EXISTS caldav.log_calendar_change(BIGINT, UUID, TEXT, BIT);
+
+ALTER TABLE caldav.calendar_change
+    DROP COLUMN IF EXISTS name;
+
+ALTER TABLE caldav.calendar_file
+    DROP CONSTRAINT IF EXISTS calendar_file_name_key,
+    DROP CONSTRAINT IF EXISTS calendar_file_ical_uid_key,
+    ALTER COLUMN uid DROP DEFAULT,
+    DROP COLUMN IF EXISTS name,
+    DROP COLUMN IF EXISTS ical_uid;
+
+CREATE OR REPLACE FUNCTION caldav.log_calendar_change(
+    p_calendar_folder_id BIGINT,
+    p_calendar_file_uid UUID,
+    p_deleted BIT
+)
+    RETURNS VOID
+    LANGUAGE plpgsql AS
+$$
+DECLARE
+    v_revision BIGINT;
+BEGIN
+    UPDATE caldav.calendar_folder
+    SET sync_revision = sync_revision + 1
+    WHERE id = p_calendar_folder_id
+    RETURNING sync_revision INTO v_revision;
+
+    -- The folder itself is being deleted
+    IF NOT FOUND THEN
+        RETURN;
+    END IF;
+
+    INSERT INTO caldav.calendar_change (calendar_folder_id, calendar_file_uid, revision, deleted)
+    VALUES (p_calendar_folder_id, p_calendar_file_uid, v_revision, p_deleted);
+
+    -- Keep the last 10000 changes, older sync tokens expire
+    DELETE
+    FROM caldav.calendar_change
+    WHERE calendar_folder_id = p_calendar_folder_id
+      AND revision <= v_revision - 10000;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION caldav.calendar_file_change_trigger_fnc()
+    RETURNS trigger AS
+$$
+BEGIN
+    IF TG_OP = 'DELETE' OR TG_OP = 'UPDATE' AND OLD.calendar_folder_id IS DISTINCT FROM NEW.calendar_folder_id THEN
+        PERFORM caldav.log_calendar_change(OLD.calendar_folder_id, OLD.uid, B'1');
+    END IF;
+    IF TG_OP = 'INSERT' OR TG_OP = 'UPDATE' THEN
+        PERFORM caldav.log_calendar_change(NEW.calendar_folder_id, NEW.uid, B'0');
+    END IF;
+    RETURN NULL;
+END;
+$$
+    LANGUAGE 'plpgsql';
+CREATE TRIGGER calendar_file_change_trigger
+    AFTER INSERT OR UPDATE OR DELETE
+    ON caldav.calendar_file
+    FOR EACH ROW
+EXECUTE PROCEDURE caldav.calendar_file_change_trigger_fnc();
+
+CREATE OR REPLACE PROCEDURE caldav.create_or_update_calendar_file(
+    IN p_calendar_uid UUID,
+    IN p_calendar_folder_type caldav.calendar_type,
+    IN p_calendar_folder_id BIGINT,
+    IN p_etag VARCHAR(40),
+    IN p_want_etag VARCHAR(40),
+    IN p_modified_at TIMESTAMP,
+    IN p_size INT,
+    IN p_version VARCHAR(5),
+    IN p_product VARCHAR(100),
+    IN p_if_none_match BOOLEAN DEFAULT FALSE,
+    IN p_if_match BOOLEAN DEFAULT FALSE,
+    IN p_scale VARCHAR(30) DEFAULT 'GREGORIAN',
+    IN p_method VARCHAR(30) DEFAULT NULL
+)
+    LANGUAGE plpgsql AS
+$$
+DECLARE
+    v_support_folder_id BIGINT;
+    v_current_etag      VARCHAR(40);
+BEGIN
+    SELECT f.id
+    INTO
+        v_support_folder_id
+    FROM caldav.calendar_folder f
+    WHERE f.id = p_calendar_folder_id;
+
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'Folder not found: %', p_calendar_folder_id
+            USING ERRCODE = 'DV404';
+    END IF;
+
+    PERFORM
+    FROM caldav.calendar_folder f
+    WHERE f.id = p_calendar_folder_id
+      AND p_calendar_folder_type = ANY (f.types);
+
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'Invalid folder type provided for folder: %', p_calendar_folder_id
+            USING ERRCODE = 'DV403';
+    END IF;
+
+    SELECT etag
+    INTO
+        v_current_etag
+    FROM caldav.calendar_file
+    WHERE uid = p_calendar_uid;
+
+    IF FOUND THEN
+        IF p_if_none_match THEN
+            RAISE EXCEPTION 'Precondition failed: If-None-Match header is set and resource exists'
+                USING ERRCODE = 'DV412';
+        END IF;
+
+        IF p_if_match AND v_current_etag IS DISTINCT FROM p_want_etag THEN
+            RAISE EXCEPTION 'Precondition failed: If-Match header is set and ETag does not match'
+                USING ERRCODE = 'DV412';
+        END IF;
+
+        UPDATE
+            caldav.calendar_file
+        SET etag        = p_etag,
+            modified_at = p_modified_at,
+            size        = p_size
+        WHERE uid = p_calendar_uid;
+    ELSE
+        IF p_if_match THEN
+            RAISE EXCEPTION 'Precondition failed: If-Match header is set and resource does not exist'
+                USING ERRCODE = 'DV412';
+        END IF;
+
+        INSERT INTO caldav.calendar_file (uid, calendar_folder_id, etag, created_at, modified_at, size)
+        VALUES (p_calendar_uid, p_calendar_folder_id, p_etag, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, p_size);
+
+        INSERT INTO caldav.calendar_property (calendar_file_uid, version, product, scale, method)
+        VALUES (p_calendar_uid, p_version, p_product, p_scale, p_method);
+    END IF;
+END;
+$$;
+
+COMMIT;
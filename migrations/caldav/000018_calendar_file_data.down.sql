@@ -0,0 +1,103 @@
+BEGIN;
+
+DROP PROCEDURE IF EXISTS caldav.create_or_update_calendar_file(
+    UUID, TEXT, TEXT, caldav.calendar_type, BIGINT, VARCHAR, VARCHAR, TIMESTAMP, INT, VARCHAR, VARCHAR,
+    BOOLEAN, BOOLEAN, VARCHAR, VARCHAR, BYTEA
+    );
+
+-- The file is looked up by its folder and name, its internal uid is returned
+CREATE OR REPLACE PROCEDURE caldav.create_or_update_calendar_file(
+    INOUT p_calendar_file_uid UUID,
+    IN p_name TEXT,
+    IN p_ical_uid TEXT,
+    IN p_calendar_folder_type caldav.calendar_type,
+    IN p_calendar_folder_id BIGINT,
+    IN p_etag VARCHAR(40),
+    IN p_want_etag VARCHAR(40),
+    IN p_modified_at TIMESTAMP,
+    IN p_size INT,
+    IN p_version VARCHAR(5),
+    IN p_product VARCHAR(100),
+    IN p_if_none_match BOOLEAN DEFAULT FALSE,
+    IN p_if_match BOOLEAN DEFAULT FALSE,
+    IN p_scale VARCHAR(30) DEFAULT 'GREGORIAN',
+    IN p_method VARCHAR(30) DEFAULT NULL
+)
+    LANGUAGE plpgsql AS
+$$
+DECLARE
+    v_support_folder_id BIGINT;
+    v_current_etag      VARCHAR(40);
+    v_current_ical_uid  TEXT;
+BEGIN
+    SELECT f.id
+    INTO
+        v_support_folder_id
+    FROM caldav.calendar_folder f
+    WHERE f.id = p_calendar_folder_id;
+
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'Folder not found: %', p_calendar_folder_id
+            USING ERRCODE = 'DV404';
+    END IF;
+
+    PERFORM
+    FROM caldav.calendar_folder f
+    WHERE f.id = p_calendar_folder_id
+      AND p_calendar_folder_type = ANY (f.types);
+
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'Invalid folder type provided for folder: %', p_calendar_folder_id
+            USING ERRCODE = 'DV403';
+    END IF;
+
+    SELECT uid, etag, ical_uid
+    INTO
+        p_calendar_file_uid, v_current_etag, v_current_ical_uid
+    FROM caldav.calendar_file
+    WHERE calendar_folder_id = p_calendar_folder_id
+      AND name = p_name
+        FOR UPDATE;
+
+    IF FOUND THEN
+        IF p_if_none_match THEN
+            RAISE EXCEPTION 'Precondition failed: If-None-Match header is set and resource exists'
+                USING ERRCODE = 'DV412';
+        END IF;
+
+        IF p_if_match AND v_current_etag IS DISTINCT FROM p_want_etag THEN
+            RAISE EXCEPTION 'Precondition failed: If-Match header is set and ETag does not match'
+                USING ERRCODE = 'DV412';
+        END IF;
+
+        IF v_current_ical_uid IS DISTINCT FROM p_ical_uid THEN
+            RAISE EXCEPTION 'UID of resource % can''t change from %', p_name, v_current_ical_uid
+                USING ERRCODE = 'DV403';
+        END IF;
+
+        UPDATE
+            caldav.calendar_file
+        SET etag        = p_etag,
+            modified_at = p_modified_at,
+            size        = p_size
+        WHERE uid = p_calendar_file_uid;
+    ELSE
+        IF p_if_match THEN
+            RAISE EXCEPTION 'Precondition failed: If-Match header is set and resource does not exist'
+                USING ERRCODE = 'DV412';
+        END IF;
+
+        INSERT INTO caldav.calendar_file (calendar_folder_id, name, ical_uid, etag, created_at, modified_at, size)
+        VALUES (p_calendar_folder_id, p_name, p_ical_uid, p_etag, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, p_size)
+        RETURNING uid INTO p_calendar_file_uid;
+
+        INSERT INTO caldav.calendar_property (calendar_file_uid, version, product, scale, method)
+        VALUES (p_calendar_file_uid, p_version, p_product, p_scale, p_method);
+    END IF;
+END;
+$$;
+
+ALTER TABLE caldav.calendar_file
+    DROP COLUMN IF EXISTS data;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+-- Values used to follow UNTIL, set while the rule had none
+UPDATE caldav.recurrence
+SET this_and_future = CASE WHEN frequency IS NOT NULL AND until IS NULL THEN B'1' ELSE B'0' END;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- this_and_future marks the series split off another one at a
+-- RANGE=THISANDFUTURE override, which no series stored before was
+UPDATE caldav.recurrence
+SET this_and_future = B'0';
+
+COMMIT;
@@ -3,22 +3,97 @@ package postgres
 import (
 	"errors"
 	"fmt"
+	"net/http"
 
+	"github.com/ceres919/go-webdav"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// Kinds of repository errors, matched with errors.Is.
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrForbidden          = errors.New("forbidden")
+)
+
+// HTTPError serves the kinds of repository errors with their HTTP status,
+// other errors are left as they are.
+func HTTPError(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return webdav.NewHTTPError(http.StatusNotFound, err)
+	case errors.Is(err, ErrForbidden):
+		return webdav.NewHTTPError(http.StatusForbidden, err)
+	case errors.Is(err, ErrConflict):
+		return webdav.NewHTTPError(http.StatusConflict, err)
+	case errors.Is(err, ErrPreconditionFailed):
+		return webdav.NewHTTPError(http.StatusPreconditionFailed, err)
+	}
+	return err
+}
+
+// SQLSTATE codes raised by the procedures of the schema.
+const (
+	CodeForbidden          = "DV403"
+	CodeNotFound           = "DV404"
+	CodePreconditionFailed = "DV412"
+)
+
+// SQLSTATE codes of PostgreSQL mapped to a kind of error.
+const (
+	codeNoDataFound           = "P0002"
+	codeForeignKeyViolation   = "23503"
+	codeUniqueViolation       = "23505"
+	codeInsufficientPrivilege = "42501"
+)
+
+// Error is a failed statement, of the kind given by its SQLSTATE if known.
+type Error struct {
+	Kind  error
+	PgErr *pgconn.PgError
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf(
+		"repo error: %s, detail: %s, where: %s, code: %s, state: %v",
+		e.PgErr.Message,
+		e.PgErr.Detail,
+		e.PgErr.Where,
+		e.PgErr.Code,
+		e.PgErr.SQLState(),
+	)
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.PgErr}
+	}
+	return []error{e.Kind, e.PgErr}
+}
+
+func errorKind(code string) error {
+	switch code {
+	case CodeNotFound, codeNoDataFound:
+		return ErrNotFound
+	case CodeForbidden, codeInsufficientPrivilege:
+		return ErrForbidden
+	case CodePreconditionFailed:
+		return ErrPreconditionFailed
+	case codeUniqueViolation, codeForeignKeyViolation:
+		return ErrConflict
+	}
+	return nil
+}
+
 func (p *Postgres) ToPgErr(err error) error {
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) {
-		return fmt.Errorf(
-			"repo error: %s, detail: %s, where: %s, code: %s, state: %v",
-			pgErr.Message,
-			pgErr.Detail,
-			pgErr.Where,
-			pgErr.Code,
-			pgErr.SQLState(),
-		)
+		return &Error{Kind: errorKind(pgErr.Code), PgErr: pgErr}
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("repo error: %w: %w", ErrNotFound, err)
 	}
 	return err
 }
@@ -0,0 +1,17 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+)
+
+func TestAlarm_Reminders(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
+
+func TestAlarm_AbsoluteTrigger(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/emersion/go-ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachment_InlineAndURI(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
+
+func TestAttachment_ManagedAddRemove(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	content := []byte("minutes of the retro")
+	resp := st.Do(ctx, http.MethodPost, objPath+"?action=attachment-add", map[string]string{
+		"Content-Type":        "text/plain",
+		"Content-Disposition": `attachment;filename="minutes.txt"`,
+	}, bytes.NewReader(content))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	managedID := resp.Header.Get("Cal-Managed-ID")
+	require.NotEmpty(t, managedID)
+	assert.NotEmpty(t, resp.Header.Get("ETag"))
+
+	obj, err := st.Client.GetCalendarObject(ctx, objPath)
+	require.NoError(t, err)
+	require.Len(t, obj.Data.Children, 1)
+	attach := obj.Data.Children[0].Props.Get(ical.PropAttach)
+	require.NotNil(t, attach)
+	assert.Equal(t, managedID, attach.Params.Get("MANAGED-ID"))
+	assert.Equal(t, "minutes.txt", attach.Params.Get("FILENAME"))
+	assert.Equal(t, "20", attach.Params.Get("SIZE"))
+
+	u, err := url.Parse(attach.Value)
+	require.NoError(t, err)
+	resp = st.Do(ctx, http.MethodGet, u.RequestURI(), nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	got, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+
+	resp = st.Do(ctx, http.MethodPost, objPath+"?action=attachment-remove&managed-id="+managedID, nil, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	obj, err = st.Client.GetCalendarObject(ctx, objPath)
+	require.NoError(t, err)
+	assert.Nil(t, obj.Data.Children[0].Props.Get(ical.PropAttach))
+
+	resp = st.Do(ctx, http.MethodPost, objPath+"?action=attachment-remove&managed-id="+managedID, nil, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestAttachment_MaxSize(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	cals, err := st.Client.FindCalendars(ctx, path.Dir(path.Clean(testCalPath))+"/")
+	require.NoError(t, err)
+	var maxSize int64
+	for _, cal := range cals {
+		if cal.Path == testCalPath {
+			maxSize = cal.MaxResourceSize
+		}
+	}
+	require.Greater(t, maxSize, int64(0))
+
+	resp := st.Do(ctx, http.MethodPost, objPath+"?action=attachment-add", map[string]string{
+		"Content-Type": "text/plain",
+	}, strings.NewReader(strings.Repeat("x", int(maxSize)+1)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	obj, err := st.Client.GetCalendarObject(ctx, objPath)
+	require.NoError(t, err)
+	assert.Nil(t, obj.Data.Children[0].Props.Get(ical.PropAttach))
+}
+
+func TestAttachment_NotCountedInResourceSize(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	// Base64 makes the object larger than max_size, the content itself is not
+	content := bytes.Repeat([]byte("x"), 4000)
+	resp := st.Do(ctx, http.MethodPost, objPath+"?action=attachment-add", map[string]string{
+		"Content-Type": "text/plain",
+	}, bytes.NewReader(content))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
@@ -0,0 +1,12 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+)
+
+func TestAttendee_Meeting(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
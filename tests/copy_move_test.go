@@ -0,0 +1,253 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/internal/caldav/db"
+	"github.com/Raimguzhinov/dav-go/pkg/logger"
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/ceres919/go-webdav/caldav"
+	"github.com/emersion/go-ical"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCalendar creates another calendar of the test user, removed with the test.
+func newCalendar(ctx context.Context, t *testing.T, st *suite.Suite, testCalPath string, types ...string) string {
+	repo := db.NewRepository(st.Pg, logger.New("error", "prod"))
+	homeSetPath := path.Dir(path.Clean(testCalPath)) + "/"
+	cal := caldav.Calendar{
+		Name:                  "Personal " + uuid.NewString(),
+		MaxResourceSize:       4096,
+		SupportedComponentSet: types,
+	}
+	require.NoError(t, repo.CreateCalendar(ctx, st.Cfg.HTTP.User, homeSetPath, &cal))
+
+	folderID, err := strconv.Atoi(path.Base(cal.Path))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = repo.DeleteCalendar(context.Background(), st.Cfg.HTTP.User, folderID)
+	})
+	return cal.Path + "/"
+}
+
+func copyMove(ctx context.Context, st *suite.Suite, method, src, dst string, headers map[string]string) *http.Response {
+	h := map[string]string{"Destination": dst}
+	for k, v := range headers {
+		h[k] = v
+	}
+	return st.Do(ctx, method, src, h, nil)
+}
+
+func TestMove_BetweenCalendars(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	otherCalPath := newCalendar(ctx, t, st, testCalPath, ical.CompEvent)
+
+	uid := uuid.NewString()
+	src := path.Join(testCalPath, uid+suite.IcsExt)
+	dst := path.Join(otherCalPath, uid+suite.IcsExt)
+	code, _ := putRaw(ctx, t, st, src, ical.MIMEType, encodeCalendar(t, newEvent(uid, "")))
+	require.Equal(t, http.StatusCreated, code)
+	before, err := st.Client.GetCalendarObject(ctx, src)
+	require.NoError(t, err)
+
+	resp := copyMove(ctx, st, "MOVE", src, dst, map[string]string{"If-Match": `"stale"`})
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp = copyMove(ctx, st, "MOVE", src, dst, map[string]string{"If-Match": strconv.Quote(before.ETag)})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, strconv.Quote(before.ETag), resp.Header.Get("ETag"))
+
+	resp = st.Do(ctx, http.MethodGet, src, nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	after, err := st.Client.GetCalendarObject(ctx, dst)
+	require.NoError(t, err)
+	assert.Equal(t, before.ETag, after.ETag)
+
+	objs, err := st.Client.QueryCalendar(ctx, testCalPath, &caldav.CalendarQuery{
+		CompFilter: caldav.CompFilter{Name: ical.CompCalendar},
+	})
+	require.NoError(t, err)
+	for _, obj := range objs {
+		assert.NotEqual(t, src, obj.Path)
+	}
+}
+
+func TestMove_UnsupportedComponent(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	otherCalPath := newCalendar(ctx, t, st, testCalPath, ical.CompEvent)
+
+	uid := uuid.NewString()
+	src := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, src, newTask(uid, "task"))
+	require.NoError(t, err)
+
+	resp := copyMove(ctx, st, "MOVE", src, path.Join(otherCalPath, uid+suite.IcsExt), nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "supported-calendar-component")
+
+	_, err = st.Client.GetCalendarObject(ctx, src)
+	assert.NoError(t, err)
+}
+
+func TestMove_Rename(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	uid := uuid.NewString()
+	src := path.Join(testCalPath, uid+suite.IcsExt)
+	dst := path.Join(testCalPath, "renamed-"+uid+suite.IcsExt)
+	code, _ := putRaw(ctx, t, st, src, ical.MIMEType, encodeCalendar(t, newEvent(uid, "")))
+	require.Equal(t, http.StatusCreated, code)
+
+	resp := copyMove(ctx, st, "MOVE", src, dst, nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = st.Do(ctx, http.MethodGet, src, nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	obj, err := st.Client.GetCalendarObject(ctx, dst)
+	require.NoError(t, err)
+	gotUID, err := obj.Data.Children[0].Props.Text(ical.PropUID)
+	require.NoError(t, err)
+	assert.Equal(t, uid, gotUID)
+}
+
+func TestCopy_BetweenCalendars(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	otherCalPath := newCalendar(ctx, t, st, testCalPath, ical.CompEvent)
+
+	uid := uuid.NewString()
+	src := path.Join(testCalPath, uid+suite.IcsExt)
+	code, _ := putRaw(ctx, t, st, src, ical.MIMEType, encodeCalendar(t, newEvent(uid, "agenda")))
+	require.Equal(t, http.StatusCreated, code)
+
+	// A copy keeps the UID, which is already in use within the same calendar
+	resp := copyMove(ctx, st, "COPY", src, path.Join(testCalPath, "copy-"+uid+suite.IcsExt), nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "no-uid-conflict")
+	assert.Contains(t, string(body), src)
+
+	dst := path.Join(otherCalPath, "copy"+suite.IcsExt)
+	resp = copyMove(ctx, st, "COPY", src, dst, nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	obj, err := st.Client.GetCalendarObject(ctx, dst)
+	require.NoError(t, err)
+	require.Len(t, obj.Data.Children, 1)
+	gotUID, err := obj.Data.Children[0].Props.Text(ical.PropUID)
+	require.NoError(t, err)
+	assert.Equal(t, uid, gotUID)
+	description, err := obj.Data.Children[0].Props.Text(ical.PropDescription)
+	require.NoError(t, err)
+	assert.Equal(t, "agenda", strings.TrimSpace(description))
+
+	_, err = st.Client.GetCalendarObject(ctx, src)
+	require.NoError(t, err)
+
+	resp = copyMove(ctx, st, "COPY", src, dst, map[string]string{"Overwrite": "F"})
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp = copyMove(ctx, st, "COPY", src, dst, map[string]string{"Overwrite": "T"})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+// objectUID returns the UID of the object stored under the path.
+func objectUID(ctx context.Context, t *testing.T, st *suite.Suite, objPath string) string {
+	t.Helper()
+	obj, err := st.Client.GetCalendarObject(ctx, objPath)
+	require.NoError(t, err)
+	uid, err := obj.Data.Children[0].Props.Text(ical.PropUID)
+	require.NoError(t, err)
+	return uid
+}
+
+func TestMove_OverwriteWithinCalendar(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	uid, otherUID := uuid.NewString(), uuid.NewString()
+	src := path.Join(testCalPath, uid+suite.IcsExt)
+	dst := path.Join(testCalPath, otherUID+suite.IcsExt)
+	code, _ := putRaw(ctx, t, st, src, ical.MIMEType, encodeCalendar(t, newEvent(uid, "")))
+	require.Equal(t, http.StatusCreated, code)
+	code, _ = putRaw(ctx, t, st, dst, ical.MIMEType, encodeCalendar(t, newEvent(otherUID, "")))
+	require.Equal(t, http.StatusCreated, code)
+
+	resp := copyMove(ctx, st, "MOVE", src, dst, map[string]string{"Overwrite": "T"})
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = st.Do(ctx, http.MethodGet, src, nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, uid, objectUID(ctx, t, st, dst))
+
+	// The replaced object is gone with its UID, which can be used again
+	code, _ = putRaw(ctx, t, st, src, ical.MIMEType, encodeCalendar(t, newEvent(otherUID, "")))
+	assert.Equal(t, http.StatusCreated, code)
+}
+
+func TestMove_OverwriteBetweenCalendars(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	otherCalPath := newCalendar(ctx, t, st, testCalPath, ical.CompEvent)
+
+	uid, otherUID := uuid.NewString(), uuid.NewString()
+	src := path.Join(testCalPath, uid+suite.IcsExt)
+	dst := path.Join(otherCalPath, otherUID+suite.IcsExt)
+	code, _ := putRaw(ctx, t, st, src, ical.MIMEType, encodeCalendar(t, newEvent(uid, "")))
+	require.Equal(t, http.StatusCreated, code)
+	code, _ = putRaw(ctx, t, st, dst, ical.MIMEType, encodeCalendar(t, newEvent(otherUID, "")))
+	require.Equal(t, http.StatusCreated, code)
+
+	resp := copyMove(ctx, st, "MOVE", src, dst, map[string]string{"Overwrite": "F"})
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp = copyMove(ctx, st, "MOVE", src, dst, map[string]string{"Overwrite": "T"})
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = st.Do(ctx, http.MethodGet, src, nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, uid, objectUID(ctx, t, st, dst))
+}
+
+func TestCopy_OverwriteBetweenCalendars(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	otherCalPath := newCalendar(ctx, t, st, testCalPath, ical.CompEvent)
+
+	uid, otherUID := uuid.NewString(), uuid.NewString()
+	src := path.Join(testCalPath, uid+suite.IcsExt)
+	dst := path.Join(otherCalPath, otherUID+suite.IcsExt)
+	code, _ := putRaw(ctx, t, st, src, ical.MIMEType, encodeCalendar(t, newEvent(uid, "")))
+	require.Equal(t, http.StatusCreated, code)
+	code, _ = putRaw(ctx, t, st, dst, ical.MIMEType, encodeCalendar(t, newEvent(otherUID, "")))
+	require.Equal(t, http.StatusCreated, code)
+
+	resp := copyMove(ctx, st, "COPY", src, dst, map[string]string{"Overwrite": "T"})
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, uid, objectUID(ctx, t, st, dst))
+	assert.Equal(t, uid, objectUID(ctx, t, st, src))
+
+	// A third object holding the UID still conflicts
+	third := path.Join(otherCalPath, "third"+suite.IcsExt)
+	code, _ = putRaw(ctx, t, st, third, ical.MIMEType, encodeCalendar(t, newEvent(otherUID, "")))
+	require.Equal(t, http.StatusCreated, code)
+	resp = copyMove(ctx, st, "COPY", src, third, map[string]string{"Overwrite": "T"})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "no-uid-conflict")
+	assert.Equal(t, otherUID, objectUID(ctx, t, st, third))
+}
@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ctagPropfind = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:" xmlns:CS="http://calendarserver.org/ns/">
+  <D:prop>
+    <D:displayname/>
+    <CS:getctag/>
+  </D:prop>
+</D:propfind>`
+
+func getCTag(ctx context.Context, t *testing.T, st *suite.Suite, calPath string) string {
+	resp := st.Do(ctx, "PROPFIND", calPath, map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        "0",
+	}, strings.NewReader(ctagPropfind))
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+
+	var ms struct {
+		Responses []struct {
+			PropStats []struct {
+				CTag   *string `xml:"prop>getctag"`
+				Status string  `xml:"status"`
+			} `xml:"propstat"`
+		} `xml:"response"`
+	}
+	require.NoError(t, xml.NewDecoder(resp.Body).Decode(&ms))
+	require.Len(t, ms.Responses, 1)
+
+	for _, ps := range ms.Responses[0].PropStats {
+		if ps.CTag != nil {
+			assert.Contains(t, ps.Status, "200")
+			return *ps.CTag
+		}
+	}
+	t.Fatal("getctag not found in PROPFIND response")
+	return ""
+}
+
+func TestCTag_ChangesOnPut(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	before := getCTag(ctx, t, st, testCalPath)
+	require.NotEmpty(t, before)
+	assert.Equal(t, before, getCTag(ctx, t, st, testCalPath))
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	afterPut := getCTag(ctx, t, st, testCalPath)
+	assert.NotEqual(t, before, afterPut)
+
+	require.NoError(t, st.Client.RemoveAll(ctx, objPath))
+	assert.NotEqual(t, afterPut, getCTag(ctx, t, st, testCalPath))
+}
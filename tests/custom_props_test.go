@@ -15,3 +15,8 @@ func TestCustomProps_StrongTypes(t *testing.T) {
 	ctx, st := suite.New(t, true)
 	suite.CompareContentsByTestName(ctx, t, st)
 }
+
+func TestCustomProps_IanaAndParams(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteCalendar_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	err := st.Client.RemoveAll(ctx, testCalPath)
+	require.NoError(t, err)
+
+	principal, err := st.Client.FindCurrentUserPrincipal(ctx)
+	require.NoError(t, err)
+	calendarHomeSet, err := st.Client.FindCalendarHomeSet(ctx, principal)
+	require.NoError(t, err)
+
+	calendars, err := st.Client.FindCalendars(ctx, calendarHomeSet)
+	require.NoError(t, err)
+	for _, calendar := range calendars {
+		assert.NotEqual(t, testCalPath, calendar.Path)
+	}
+}
+
+func TestDeleteCalendar_HomeSet(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	principal, err := st.Client.FindCurrentUserPrincipal(ctx)
+	require.NoError(t, err)
+	calendarHomeSet, err := st.Client.FindCalendarHomeSet(ctx, principal)
+	require.NoError(t, err)
+
+	err = st.Client.RemoveAll(ctx, calendarHomeSet)
+	require.Error(t, err)
+}
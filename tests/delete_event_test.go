@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteEvent_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	reqObj, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+	assert.NotEmpty(t, reqObj)
+
+	err = st.Client.RemoveAll(ctx, objPath)
+	require.NoError(t, err)
+
+	respObj, err := st.Client.GetCalendarObject(ctx, objPath)
+	require.Error(t, err)
+	assert.Nil(t, respObj)
+
+	err = st.Client.RemoveAll(ctx, objPath)
+	require.Error(t, err)
+}
+
+func TestDeleteEvent_IfMatchMismatch(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	reqObj, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+	assert.NotEmpty(t, reqObj)
+
+	resp := st.Do(ctx, http.MethodDelete, objPath, map[string]string{
+		"If-Match": fmt.Sprintf("%q", "stale-etag"),
+	}, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	respObj, err := st.Client.GetCalendarObject(ctx, objPath)
+	require.NoError(t, err)
+	assert.NotEmpty(t, respObj)
+}
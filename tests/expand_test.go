@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const expandQuery = `<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop>
+    <C:calendar-data>
+      <C:expand start="20240601T000000Z" end="20240701T000000Z"/>
+    </C:calendar-data>
+  </D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT">
+        <C:time-range start="20240601T000000Z" end="20240701T000000Z"/>
+      </C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>`
+
+func TestExpand_WeeklyInstances(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	resp := st.Do(ctx, "REPORT", testCalPath, map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        "1",
+	}, strings.NewReader(expandQuery))
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	data := string(body)
+
+	// June 2024 has four Mondays, one of them excluded by EXDATE
+	assert.Equal(t, 3, strings.Count(data, "RECURRENCE-ID:"))
+	assert.Contains(t, data, "DTSTART:20240603T060000Z")
+	assert.NotContains(t, data, "DTSTART:20240610T060000Z")
+	assert.Contains(t, data, "DTSTART:20240624T060000Z")
+	assert.NotContains(t, data, "RRULE")
+	assert.NotContains(t, data, "VTIMEZONE")
+}
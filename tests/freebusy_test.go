@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func freeBusyQuery(ctx context.Context, t *testing.T, st *suite.Suite, calPath, start, end string) string {
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<C:free-busy-query xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <C:time-range start="%s" end="%s"/>
+</C:free-busy-query>`, start, end)
+
+	resp := st.Do(ctx, "REPORT", calPath, map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        "1",
+	}, strings.NewReader(body))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/calendar")
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestFreeBusy_RecurringWithCancelledInstance(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	fb := freeBusyQuery(ctx, t, st, testCalPath, "20240902T000000Z", "20240905T000000Z")
+	assert.Contains(t, fb, "BEGIN:VFREEBUSY")
+	assert.Contains(t, fb, "FREEBUSY:20240902T090000Z/20240902T100000Z")
+	assert.NotContains(t, fb, "20240903T090000Z/20240903T100000Z")
+	assert.Contains(t, fb, "FREEBUSY:20240904T090000Z/20240904T100000Z")
+}
+
+func TestFreeBusy_TransparentEvent(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	fb := freeBusyQuery(ctx, t, st, testCalPath, "20240910T000000Z", "20240911T000000Z")
+	assert.Contains(t, fb, "BEGIN:VFREEBUSY")
+	assert.NotContains(t, fb, "FREEBUSY:")
+}
+
+func TestFreeBusy_RDatePeriod(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	fb := freeBusyQuery(ctx, t, st, testCalPath, "20240902T000000Z", "20240905T000000Z")
+	assert.Contains(t, fb, "FREEBUSY:20240902T090000Z/20240902T100000Z")
+	assert.Contains(t, fb, "FREEBUSY:20240904T130000Z/20240904T153000Z")
+}
@@ -1,10 +1,16 @@
 package tests
 
 import (
+	"fmt"
+	"net/http"
+	"path"
 	"testing"
 
 	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/ceres919/go-webdav"
+	"github.com/ceres919/go-webdav/caldav"
 	"github.com/emersion/go-ical"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -56,3 +62,106 @@ func TestGetCalendars_IncorrectHomeSetPath(t *testing.T) {
 	require.Error(t, err)
 	require.Empty(t, calendars)
 }
+
+func TestGetCalendars_ForeignCalendarHidden(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	foreignName := "Foreign Calendar for (" + t.Name() + ")"
+
+	_, err := st.Pg.Pool.Exec(ctx, `
+		WITH f AS (
+			INSERT INTO caldav.calendar_folder (name) VALUES ($1) RETURNING id
+		)
+		INSERT INTO caldav.access (calendar_folder_id, user_id, owner, read, write)
+		SELECT id, $2, B'1', B'1', B'1' FROM f
+	`, foreignName, "not-"+st.Cfg.HTTP.User)
+	require.NoError(t, err)
+
+	principal, err := st.Client.FindCurrentUserPrincipal(ctx)
+	require.NoError(t, err)
+	calendarHomeSet, err := st.Client.FindCalendarHomeSet(ctx, principal)
+	require.NoError(t, err)
+
+	calendars, err := st.Client.FindCalendars(ctx, calendarHomeSet)
+	require.NoError(t, err)
+
+	var names []string
+	for _, calendar := range calendars {
+		names = append(names, calendar.Name)
+	}
+	assert.Contains(t, names, st.TestFolder["name"])
+	assert.NotContains(t, names, foreignName)
+}
+
+func TestGetCalendars_ListingCreatesNothing(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	user := "new-" + uuid.NewString()
+
+	httpClient := webdav.HTTPClientWithBasicAuth(&http.Client{}, user, st.Cfg.HTTP.Password)
+	client, err := caldav.NewClient(httpClient, fmt.Sprintf("http://%s:%s", st.Cfg.HTTP.IP, st.Cfg.HTTP.Port))
+	require.NoError(t, err)
+
+	principal, err := client.FindCurrentUserPrincipal(ctx)
+	require.NoError(t, err)
+	calendarHomeSet, err := client.FindCalendarHomeSet(ctx, principal)
+	require.NoError(t, err)
+
+	for range 2 {
+		calendars, err := client.FindCalendars(ctx, calendarHomeSet)
+		require.NoError(t, err)
+		assert.Empty(t, calendars)
+	}
+
+	var count int
+	err = st.Pg.Pool.QueryRow(ctx, `SELECT count(*) FROM caldav.access WHERE user_id = $1`, user).Scan(&count)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestGetCalendars_UnownedCalendarGranted(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	user := "legacy-" + uuid.NewString()
+	legacyName := "Legacy Calendar for (" + t.Name() + ")"
+
+	// Calendars stored before access was recorded have no row
+	_, err := st.Pg.Pool.Exec(ctx, `
+		INSERT INTO caldav.calendar_folder (name, types)
+		VALUES ($1, ARRAY ['VEVENT', 'VTODO', 'VJOURNAL']::caldav.calendar_type[])
+	`, legacyName)
+	require.NoError(t, err)
+
+	httpClient := webdav.HTTPClientWithBasicAuth(&http.Client{}, user, st.Cfg.HTTP.Password)
+	client, err := caldav.NewClient(httpClient, fmt.Sprintf("http://%s:%s", st.Cfg.HTTP.IP, st.Cfg.HTTP.Port))
+	require.NoError(t, err)
+	principal, err := client.FindCurrentUserPrincipal(ctx)
+	require.NoError(t, err)
+	calendarHomeSet, err := client.FindCalendarHomeSet(ctx, principal)
+	require.NoError(t, err)
+
+	calendars, err := client.FindCalendars(ctx, calendarHomeSet)
+	require.NoError(t, err)
+	assert.Empty(t, calendars)
+
+	_, err = st.Pg.Pool.Exec(ctx, `CALL caldav.grant_unowned_folders($1)`, user)
+	require.NoError(t, err)
+
+	calendars, err = client.FindCalendars(ctx, calendarHomeSet)
+	require.NoError(t, err)
+	var legacy *caldav.Calendar
+	for i := range calendars {
+		if calendars[i].Name == legacyName {
+			legacy = &calendars[i]
+		}
+	}
+	require.NotNil(t, legacy)
+
+	// Its objects are reachable by the principal too
+	uid := uuid.NewString()
+	objPath := path.Join(legacy.Path, uid+suite.IcsExt)
+	_, err = client.PutCalendarObject(ctx, objPath, newEvent(uid, "legacy"))
+	require.NoError(t, err)
+	obj, err := client.GetCalendarObject(ctx, objPath)
+	require.NoError(t, err)
+	summary, err := obj.Data.Events()[0].Props.Text(ical.PropSummary)
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", summary)
+}
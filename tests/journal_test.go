@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/ceres919/go-webdav/caldav"
+	"github.com/emersion/go-ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal_MultipleDescriptions(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
+
+func TestJournal_TimeRangeQuery(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	journalQuery := func(start, end time.Time) *caldav.CalendarQuery {
+		query := timeRangeQuery(start, end)
+		query.CompFilter.Comps[0].Name = ical.CompJournal
+		return query
+	}
+
+	week := time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC)
+	objs, err := st.Client.QueryCalendar(ctx, testCalPath, journalQuery(week, week.AddDate(0, 0, 7)))
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	assert.Equal(t, objPath, objs[0].Path)
+	assert.Equal(t, ical.CompJournal, objs[0].Data.Children[0].Name)
+
+	objs, err = st.Client.QueryCalendar(ctx, testCalPath, timeRangeQuery(week, week.AddDate(0, 0, 7)))
+	require.NoError(t, err)
+	assert.Empty(t, objs)
+
+	week = week.AddDate(0, 0, 7)
+	objs, err = st.Client.QueryCalendar(ctx, testCalPath, journalQuery(week, week.AddDate(0, 0, 7)))
+	require.NoError(t, err)
+	assert.Empty(t, objs)
+}
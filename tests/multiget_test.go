@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/ceres919/go-webdav/caldav"
+	"github.com/emersion/go-ical"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiget_Batch(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	var paths []string
+	for i := 0; i < 3; i++ {
+		uid := uuid.NewString()
+		objPath := path.Join(testCalPath, uid+suite.IcsExt)
+		code, _ := putRaw(ctx, t, st, objPath, ical.MIMEType, encodeCalendar(t, newEvent(uid, fmt.Sprint("item ", i))))
+		require.Equal(t, http.StatusCreated, code)
+		paths = append(paths, objPath)
+	}
+
+	objs, err := st.Client.MultiGetCalendar(ctx, testCalPath, &caldav.CalendarMultiGet{
+		Paths:       paths,
+		CompRequest: caldav.CalendarCompRequest{Name: ical.CompCalendar, AllProps: true, AllComps: true},
+	})
+	require.NoError(t, err)
+	require.Len(t, objs, len(paths))
+	for i, obj := range objs {
+		assert.Equal(t, paths[i], obj.Path)
+
+		single, err := st.Client.GetCalendarObject(ctx, paths[i])
+		require.NoError(t, err)
+		assert.Equal(t, single.ETag, obj.ETag)
+		assert.Equal(t, encodeCalendar(t, single.Data), encodeCalendar(t, obj.Data))
+	}
+
+	missing := path.Join(testCalPath, uuid.NewString()+suite.IcsExt)
+	resp := st.Do(ctx, "REPORT", testCalPath, map[string]string{
+		"Content-Type": "application/xml",
+		"Depth":        "1",
+	}, strings.NewReader(`<?xml version="1.0" encoding="utf-8" ?>
+<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/></D:prop>
+  <D:href>`+paths[0]+`</D:href>
+  <D:href>`+missing+`</D:href>
+</C:calendar-multiget>`))
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), paths[0])
+	assert.Contains(t, string(body), missing+"</href><status>HTTP/1.1 404 Not Found</status>")
+}
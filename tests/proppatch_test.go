@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const berlinTimezone = `BEGIN:VCALENDAR
+PRODID:-//xyz Protei-Lab//Raimguzhinov DAV-GO V1.0.0//EN
+VERSION:2.0
+BEGIN:VTIMEZONE
+TZID:Europe/Berlin
+BEGIN:STANDARD
+DTSTART:19701025T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+END:STANDARD
+END:VTIMEZONE
+END:VCALENDAR`
+
+const collectionPropfind = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:A="http://apple.com/ns/ical/">
+  <D:prop>
+    <D:displayname/>
+    <C:calendar-description/>
+    <C:calendar-timezone/>
+    <A:calendar-color/>
+    <A:calendar-order/>
+  </D:prop>
+</D:propfind>`
+
+type propStatus struct {
+	Status string `xml:"status"`
+	Prop   struct {
+		Values []struct {
+			XMLName xml.Name
+			Text    string `xml:",chardata"`
+		} `xml:",any"`
+	} `xml:"prop"`
+}
+
+// propStatuses returns the status and the value of every property of the
+// single response of a multistatus.
+func propStatuses(t *testing.T, resp *http.Response) (map[string]string, map[string]string) {
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+
+	var ms struct {
+		Responses []struct {
+			PropStats []propStatus `xml:"propstat"`
+		} `xml:"response"`
+	}
+	require.NoError(t, xml.NewDecoder(resp.Body).Decode(&ms))
+	require.Len(t, ms.Responses, 1)
+
+	statuses := make(map[string]string)
+	values := make(map[string]string)
+	for _, ps := range ms.Responses[0].PropStats {
+		for _, v := range ps.Prop.Values {
+			statuses[v.XMLName.Local] = strings.Fields(ps.Status)[1]
+			values[v.XMLName.Local] = v.Text
+		}
+	}
+	return statuses, values
+}
+
+func proppatch(ctx context.Context, st *suite.Suite, calPath, instructions string) *http.Response {
+	return st.Do(ctx, "PROPPATCH", calPath, map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+	}, strings.NewReader(`<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:A="http://apple.com/ns/ical/">`+
+		instructions+`</D:propertyupdate>`))
+}
+
+func collectionProps(ctx context.Context, t *testing.T, st *suite.Suite, calPath string) (map[string]string, map[string]string) {
+	return propStatuses(t, st.Do(ctx, "PROPFIND", calPath, map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        "0",
+	}, strings.NewReader(collectionPropfind)))
+}
+
+func TestProppatch_CalendarProps(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	before := getCTag(ctx, t, st, testCalPath)
+
+	statuses, _ := propStatuses(t, proppatch(ctx, st, testCalPath, `
+  <D:set><D:prop>
+    <D:displayname>Work</D:displayname>
+    <C:calendar-description>Meetings &amp; reviews</C:calendar-description>
+    <C:calendar-timezone><![CDATA[`+berlinTimezone+`]]></C:calendar-timezone>
+    <A:calendar-color>#FF2968FF</A:calendar-color>
+    <A:calendar-order>3</A:calendar-order>
+  </D:prop></D:set>`))
+	assert.Equal(t, map[string]string{
+		"displayname":          "200",
+		"calendar-description": "200",
+		"calendar-timezone":    "200",
+		"calendar-color":       "200",
+		"calendar-order":       "200",
+	}, statuses)
+	assert.NotEqual(t, before, getCTag(ctx, t, st, testCalPath))
+
+	statuses, values := collectionProps(ctx, t, st, testCalPath)
+	for name, status := range statuses {
+		assert.Equal(t, "200", status, name)
+	}
+	assert.Equal(t, "Work", values["displayname"])
+	assert.Equal(t, "Meetings & reviews", values["calendar-description"])
+	assert.Equal(t, "#FF2968FF", values["calendar-color"])
+	assert.Equal(t, "3", values["calendar-order"])
+	assert.Contains(t, values["calendar-timezone"], "TZID:Europe/Berlin")
+
+	statuses, _ = propStatuses(t, proppatch(ctx, st, testCalPath, `
+  <D:remove><D:prop><A:calendar-color/><A:calendar-order/></D:prop></D:remove>`))
+	assert.Equal(t, map[string]string{"calendar-color": "200", "calendar-order": "200"}, statuses)
+
+	statuses, _ = collectionProps(ctx, t, st, testCalPath)
+	assert.Equal(t, "404", statuses["calendar-color"])
+	assert.Equal(t, "404", statuses["calendar-order"])
+	assert.Equal(t, "200", statuses["displayname"])
+}
+
+func TestProppatch_AllOrNothing(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	_, values := collectionProps(ctx, t, st, testCalPath)
+	name := values["displayname"]
+
+	statuses, _ := propStatuses(t, proppatch(ctx, st, testCalPath, `
+  <D:set><D:prop>
+    <D:displayname>Renamed</D:displayname>
+    <A:calendar-color>blue</A:calendar-color>
+    <D:getetag>"forged"</D:getetag>
+  </D:prop></D:set>`))
+	assert.Equal(t, map[string]string{
+		"displayname":    "424",
+		"calendar-color": "409",
+		"getetag":        "403",
+	}, statuses)
+
+	_, values = collectionProps(ctx, t, st, testCalPath)
+	assert.Equal(t, name, values["displayname"])
+}
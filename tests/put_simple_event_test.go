@@ -1,10 +1,18 @@
 package tests
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/emersion/go-ical"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -43,3 +51,128 @@ func TestPutSimpleEvent_WithoutVersion(t *testing.T) {
 	require.Error(t, err)
 	assert.Nil(t, respObj)
 }
+
+func TestPutSimpleEvent_UnsupportedCalendarData(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	objPath := path.Join(testCalPath, uuid.NewString()+suite.IcsExt)
+
+	code, body := putRaw(ctx, t, st, objPath, "application/json", encodeCalendar(t, newEvent(uuid.NewString(), "")))
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Contains(t, body, "supported-calendar-data")
+}
+
+func TestPutSimpleEvent_InvalidCalendarData(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	objPath := path.Join(testCalPath, uuid.NewString()+suite.IcsExt)
+
+	code, body := putRaw(ctx, t, st, objPath, ical.MIMEType, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n")
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Contains(t, body, "valid-calendar-data")
+}
+
+func TestPutSimpleEvent_UnsupportedComponent(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	uid := uuid.NewString()
+
+	cal := newEvent(uid, "")
+	cal.Children[0].Name = ical.CompFreeBusy
+	code, body := putRaw(ctx, t, st, path.Join(testCalPath, uid+suite.IcsExt), ical.MIMEType, encodeCalendar(t, cal))
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Contains(t, body, "supported-calendar-component")
+}
+
+func TestPutSimpleEvent_MaxResourceSize(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	uid := uuid.NewString()
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+
+	cal := newEvent(uid, strings.Repeat("x", 8192))
+	code, body := putRaw(ctx, t, st, objPath, ical.MIMEType, encodeCalendar(t, cal))
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Contains(t, body, "max-resource-size")
+
+	_, err := st.Client.GetCalendarObject(ctx, objPath)
+	require.Error(t, err)
+}
+
+func newEvent(uid, description string) *ical.Calendar {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropProductID, "-//xyz Protei-Lab//Raimguzhinov DAV-GO V1.0.0//EN")
+	cal.Props.SetText(ical.PropVersion, "2.0")
+
+	event := ical.NewComponent(ical.CompEvent)
+	event.Props.SetText(ical.PropUID, uid)
+	event.Props.SetText(ical.PropSummary, "standup")
+	event.Props.SetDateTime(ical.PropDateTimeStamp, time.Date(2024, 7, 8, 9, 0, 0, 0, time.UTC))
+	event.Props.SetDateTime(ical.PropDateTimeStart, time.Date(2024, 7, 8, 10, 0, 0, 0, time.UTC))
+	event.Props.SetDateTime(ical.PropDateTimeEnd, time.Date(2024, 7, 8, 10, 15, 0, 0, time.UTC))
+	if description != "" {
+		event.Props.SetText(ical.PropDescription, description)
+	}
+	cal.Children = append(cal.Children, event)
+	return cal
+}
+
+func encodeCalendar(t *testing.T, cal *ical.Calendar) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, ical.NewEncoder(&buf).Encode(cal))
+	return buf.String()
+}
+
+// putRaw sends the body as a PUT and returns the status and the response body.
+func putRaw(ctx context.Context, t *testing.T, st *suite.Suite, objPath, contentType, body string) (int, string) {
+	t.Helper()
+	resp := st.Do(ctx, http.MethodPut, objPath, map[string]string{
+		"Content-Type": contentType,
+	}, strings.NewReader(body))
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(data)
+}
+
+func TestPutSimpleEvent_ConditionalPut(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	uid := uuid.NewString()
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	body := encodeCalendar(t, newEvent(uid, ""))
+
+	resp := st.Do(ctx, http.MethodPut, objPath, map[string]string{
+		"Content-Type": ical.MIMEType,
+		"If-Match":     `"missing"`,
+	}, strings.NewReader(body))
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	code, _ := putRaw(ctx, t, st, objPath, ical.MIMEType, body)
+	require.Equal(t, http.StatusCreated, code)
+
+	resp = st.Do(ctx, http.MethodPut, objPath, map[string]string{
+		"Content-Type":  ical.MIMEType,
+		"If-None-Match": "*",
+	}, strings.NewReader(body))
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp = st.Do(ctx, http.MethodPut, objPath, map[string]string{
+		"Content-Type": ical.MIMEType,
+		"If-Match":     `"stale"`,
+	}, strings.NewReader(body))
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+}
+
+func TestPutSimpleEvent_MissingCalendar(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	uid := uuid.NewString()
+	objPath := path.Join(path.Dir(path.Clean(testCalPath)), "999999", uid+suite.IcsExt)
+
+	code, _ := putRaw(ctx, t, st, objPath, ical.MIMEType, encodeCalendar(t, newEvent(uid, "")))
+	assert.Equal(t, http.StatusNotFound, code)
+
+	resp := st.Do(ctx, http.MethodGet, path.Join(testCalPath, uid+suite.IcsExt), nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/ceres919/go-webdav/caldav"
+	"github.com/emersion/go-ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func timeRangeQuery(start, end time.Time) *caldav.CalendarQuery {
+	return &caldav.CalendarQuery{
+		CompRequest: caldav.CalendarCompRequest{Name: ical.CompCalendar, AllProps: true, AllComps: true},
+		CompFilter: caldav.CompFilter{
+			Name: ical.CompCalendar,
+			Comps: []caldav.CompFilter{{
+				Name:  ical.CompEvent,
+				Start: start,
+				End:   end,
+			}},
+		},
+	}
+}
+
+func TestQueryCalendar_TimeRange(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	week := time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC)
+	objs, err := st.Client.QueryCalendar(ctx, testCalPath, timeRangeQuery(week, week.AddDate(0, 0, 7)))
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	assert.Equal(t, objPath, objs[0].Path)
+
+	week = week.AddDate(0, 0, 7)
+	objs, err = st.Client.QueryCalendar(ctx, testCalPath, timeRangeQuery(week, week.AddDate(0, 0, 7)))
+	require.NoError(t, err)
+	assert.Empty(t, objs)
+}
+
+func TestQueryCalendar_RecurringMaster(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	week := time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC)
+	objs, err := st.Client.QueryCalendar(ctx, testCalPath, timeRangeQuery(week, week.AddDate(0, 0, 7)))
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	assert.Equal(t, objPath, objs[0].Path)
+
+	week = time.Date(2023, 12, 4, 0, 0, 0, 0, time.UTC)
+	objs, err = st.Client.QueryCalendar(ctx, testCalPath, timeRangeQuery(week, week.AddDate(0, 0, 7)))
+	require.NoError(t, err)
+	assert.Empty(t, objs)
+}
+
+func TestQueryCalendar_RDate(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	week := time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC)
+	objs, err := st.Client.QueryCalendar(ctx, testCalPath, timeRangeQuery(week, week.AddDate(0, 0, 7)))
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	assert.Equal(t, objPath, objs[0].Path)
+
+	week = week.AddDate(0, 0, 7)
+	objs, err = st.Client.QueryCalendar(ctx, testCalPath, timeRangeQuery(week, week.AddDate(0, 0, 7)))
+	require.NoError(t, err)
+	assert.Empty(t, objs)
+}
+
+func TestQueryCalendar_OpenEndedRecurring(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	// A time-range with only a start matches the instances after it
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	objs, err := st.Client.QueryCalendar(ctx, testCalPath, timeRangeQuery(start, time.Time{}))
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	assert.Equal(t, objPath, objs[0].Path)
+}
+
+func TestQueryCalendar_OpenEndedRDate(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	start := time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC)
+	objs, err := st.Client.QueryCalendar(ctx, testCalPath, timeRangeQuery(start, time.Time{}))
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	assert.Equal(t, objPath, objs[0].Path)
+
+	objs, err = st.Client.QueryCalendar(ctx, testCalPath, timeRangeQuery(start.AddDate(0, 0, 7), time.Time{}))
+	require.NoError(t, err)
+	assert.Empty(t, objs)
+}
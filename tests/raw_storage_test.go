@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/ceres919/go-webdav/caldav"
+	"github.com/emersion/go-ical"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawStorage_ServedAsPut(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	if !st.Cfg.App.CalDAVRawStorage {
+		t.Skip("raw storage of calendar objects is disabled")
+	}
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	uid := uuid.NewString()
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	cal := newEvent(uid, "kept as is")
+	// Neither is a column of the calendar, both would be lost by normalization
+	cal.Props.SetText("X-WR-CALNAME", "Work")
+	cal.Children[0].Props.SetText(ical.PropComment, "first")
+	body := encodeCalendar(t, cal)
+
+	code, _ := putRaw(ctx, t, st, objPath, ical.MIMEType, body)
+	require.Equal(t, http.StatusCreated, code)
+
+	resp := st.Do(ctx, http.MethodGet, objPath, nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	got, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(got))
+	eTag := resp.Header.Get("ETag")
+
+	// The object is still found by the relational columns
+	objs, err := st.Client.QueryCalendar(ctx, testCalPath, &caldav.CalendarQuery{
+		CompFilter: caldav.CompFilter{
+			Name: ical.CompCalendar,
+			Comps: []caldav.CompFilter{{
+				Name:  ical.CompEvent,
+				Props: []caldav.PropFilter{{Name: ical.PropUID, TextMatch: &caldav.TextMatch{Text: uid}}},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	assert.Equal(t, eTag, strconv.Quote(objs[0].ETag))
+	calName, err := objs[0].Data.Props.Text("X-WR-CALNAME")
+	require.NoError(t, err)
+	assert.Equal(t, "Work", calName)
+}
+
+// handWrittenEvent returns an event with its properties out of the order
+// go-ical encodes them in and its lines ended by LF.
+func handWrittenEvent(uid string) string {
+	return strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//dav-go//tests//EN",
+		"BEGIN:VEVENT",
+		"UID:" + uid,
+		"SUMMARY:verbatim",
+		"DTSTART:20240101T100000Z",
+		"DTEND:20240101T110000Z",
+		"DTSTAMP:20240101T090000Z",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\n") + "\n"
+}
+
+func TestRawStorage_ServedVerbatim(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	if !st.Cfg.App.CalDAVRawStorage {
+		t.Skip("raw storage of calendar objects is disabled")
+	}
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	uid := uuid.NewString()
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	body := handWrittenEvent(uid)
+	stored := strings.ReplaceAll(body, "\n", "\r\n")
+
+	resp := st.Do(ctx, http.MethodPut, objPath, map[string]string{
+		"Content-Type": ical.MIMEType,
+	}, strings.NewReader(body))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	putETag := resp.Header.Get("ETag")
+	require.NotEmpty(t, putETag)
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		resp := st.Do(ctx, method, objPath, nil, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, putETag, resp.Header.Get("ETag"), method)
+		assert.Equal(t, strconv.Itoa(len(stored)), resp.Header.Get("Content-Length"), method)
+		if method == http.MethodGet {
+			got, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, stored, string(got))
+		}
+	}
+}
+
+func TestRawStorage_CopyKeepsData(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	if !st.Cfg.App.CalDAVRawStorage {
+		t.Skip("raw storage of calendar objects is disabled")
+	}
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	otherCalPath := newCalendar(ctx, t, st, testCalPath, ical.CompEvent)
+
+	uid := uuid.NewString()
+	src := path.Join(testCalPath, uid+suite.IcsExt)
+	dst := path.Join(otherCalPath, uid+suite.IcsExt)
+	stored := strings.ReplaceAll(handWrittenEvent(uid), "\n", "\r\n")
+	code, _ := putRaw(ctx, t, st, src, ical.MIMEType, handWrittenEvent(uid))
+	require.Equal(t, http.StatusCreated, code)
+
+	// The stored length is reported without the object being served
+	resp := st.Do(ctx, "PROPFIND", src, map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        "0",
+	}, strings.NewReader(`<?xml version="1.0" encoding="utf-8" ?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:getcontentlength/></D:prop></D:propfind>`))
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	propfind, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Regexp(t, `getcontentlength[^>]*>`+strconv.Itoa(len(stored))+`<`, string(propfind))
+
+	resp = copyMove(ctx, st, "COPY", src, dst, nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	srcResp := st.Do(ctx, http.MethodGet, src, nil, nil)
+	dstResp := st.Do(ctx, http.MethodGet, dst, nil, nil)
+	require.Equal(t, http.StatusOK, dstResp.StatusCode)
+	got, err := io.ReadAll(dstResp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, stored, string(got))
+	assert.Equal(t, srcResp.Header.Get("ETag"), dstResp.Header.Get("ETag"))
+}
@@ -1,9 +1,15 @@
 package tests
 
 import (
+	"net/http"
+	"path"
 	"testing"
 
-	"github.com/Raimguhinov/dav-go/tests/suite"
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/emersion/go-ical"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRecurrence_EveryDay(t *testing.T) {
@@ -15,3 +21,74 @@ func TestRecurrence_ByWorkdaysUntilNewYear(t *testing.T) {
 	ctx, st := suite.New(t, true)
 	suite.CompareContentsByTestName(ctx, t, st)
 }
+
+func TestRecurrence_HourlyByHour(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
+
+func TestRecurrence_HourlyTwiceADay(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
+
+func TestRecurrence_MinutelyByMinuteBySecond(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
+
+func TestRecurrence_MonthlyMixedByDay(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
+
+func TestRecurrence_MonthlyBySetPos(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
+
+func TestRecurrence_YearlyByMonthByMonthDay(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
+
+func TestRecurrence_YearlyByYearDay(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
+
+func TestRecurrence_YearlyByWeekNoWkst(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
+
+func TestRecurrence_WeeklyPartsOutOfOrder(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
+
+func TestRecurrence_RDatePeriods(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
+
+func TestRecurrence_InvalidRuleRejected(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	for _, rule := range []string{"FREQ=HOURLY;BYHOUR=25", "FREQ=FORTNIGHTLY"} {
+		uid := uuid.NewString()
+		objPath := path.Join(testCalPath, uid+suite.IcsExt)
+
+		cal := newEvent(uid, "")
+		prop := ical.NewProp(ical.PropRecurrenceRule)
+		prop.Value = rule
+		cal.Events()[0].Props.Set(prop)
+		code, body := putRaw(ctx, t, st, objPath, ical.MIMEType, encodeCalendar(t, cal))
+		assert.Equal(t, http.StatusForbidden, code, rule)
+		assert.Contains(t, body, "valid-calendar-data", rule)
+
+		_, err := st.Client.GetCalendarObject(ctx, objPath)
+		require.Error(t, err, rule)
+	}
+}
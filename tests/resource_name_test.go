@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"net/http"
+	"path"
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/ceres919/go-webdav/caldav"
+	"github.com/emersion/go-ical"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceName_NotUID(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	uid := uuid.NewString() + "@google.com"
+	objPath := path.Join(testCalPath, "standup-"+uuid.NewString()+suite.IcsExt)
+	code, _ := putRaw(ctx, t, st, objPath, ical.MIMEType, encodeCalendar(t, newEvent(uid, "")))
+	require.Equal(t, http.StatusCreated, code)
+
+	obj, err := st.Client.GetCalendarObject(ctx, objPath)
+	require.NoError(t, err)
+	assert.Equal(t, objPath, obj.Path)
+	gotUID, err := obj.Data.Children[0].Props.Text(ical.PropUID)
+	require.NoError(t, err)
+	assert.Equal(t, uid, gotUID)
+
+	objs, err := st.Client.QueryCalendar(ctx, testCalPath, &caldav.CalendarQuery{
+		CompFilter: caldav.CompFilter{Name: ical.CompCalendar},
+	})
+	require.NoError(t, err)
+	var paths []string
+	for _, o := range objs {
+		paths = append(paths, o.Path)
+	}
+	assert.Contains(t, paths, objPath)
+
+	code, _ = putRaw(ctx, t, st, objPath, ical.MIMEType, encodeCalendar(t, newEvent(uid, "updated")))
+	assert.Equal(t, http.StatusCreated, code)
+
+	require.NoError(t, st.Client.RemoveAll(ctx, objPath))
+	resp := st.Do(ctx, http.MethodGet, objPath, nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestResourceName_NoUIDConflict(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	otherCalPath := newCalendar(ctx, t, st, testCalPath, ical.CompEvent)
+
+	uid := uuid.NewString()
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	code, _ := putRaw(ctx, t, st, objPath, ical.MIMEType, encodeCalendar(t, newEvent(uid, "")))
+	require.Equal(t, http.StatusCreated, code)
+
+	// The UID is already held by another resource of the calendar
+	code, body := putRaw(ctx, t, st, path.Join(testCalPath, "other-"+uid+suite.IcsExt), ical.MIMEType,
+		encodeCalendar(t, newEvent(uid, "")))
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Contains(t, body, "no-uid-conflict")
+	assert.Contains(t, body, objPath)
+
+	// The resource holds another UID
+	code, body = putRaw(ctx, t, st, objPath, ical.MIMEType, encodeCalendar(t, newEvent(uuid.NewString(), "")))
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Contains(t, body, "no-uid-conflict")
+
+	// UIDs are unique per calendar only
+	code, _ = putRaw(ctx, t, st, path.Join(otherCalPath, uid+suite.IcsExt), ical.MIMEType,
+		encodeCalendar(t, newEvent(uid, "")))
+	assert.Equal(t, http.StatusCreated, code)
+}
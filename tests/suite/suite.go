@@ -1,8 +1,10 @@
 package suite
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"sync"
@@ -19,6 +21,7 @@ type Suite struct {
 	*testing.T
 	Cfg        *config.Config
 	Client     *caldav.Client
+	Pg         *postgres.Postgres
 	TestFolder map[string]string
 }
 
@@ -53,8 +56,15 @@ func New(t *testing.T, withAuth bool) (context.Context, *Suite) {
 
 	pg := getPg(t, cfg)
 
-	_, err := pg.Pool.Exec(context.Background(), `INSERT INTO caldav.calendar_folder (name) VALUES ($1)`,
-		testFolder["name"])
+	_, err := pg.Pool.Exec(context.Background(), `
+		WITH f AS (
+			INSERT INTO caldav.calendar_folder (name, types)
+			VALUES ($1, ARRAY ['VEVENT', 'VTODO', 'VJOURNAL']::caldav.calendar_type[])
+			RETURNING id
+		)
+		INSERT INTO caldav.access (calendar_folder_id, user_id, owner, read, write)
+		SELECT id, $2, B'1', B'1', B'1' FROM f
+	`, testFolder["name"], cfg.HTTP.User)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -83,6 +93,7 @@ func New(t *testing.T, withAuth bool) (context.Context, *Suite) {
 		T:          t,
 		Cfg:        cfg,
 		Client:     client,
+		Pg:         pg,
 		TestFolder: testFolder,
 	}
 }
@@ -127,3 +138,41 @@ func (s *Suite) CreateTestCalendar(ctx context.Context, calendarHomeSet string)
 		}
 	}()
 }
+
+// Do sends a raw authenticated request to the server, for methods and
+// headers the caldav.Client does not expose.
+func (s *Suite) Do(
+	ctx context.Context,
+	method, urlPath string,
+	headers map[string]string,
+	body io.Reader,
+) *http.Response {
+	req, err := http.NewRequest(method, fmt.Sprintf(
+		"http://%s:%s%s", s.Cfg.HTTP.IP, s.Cfg.HTTP.Port, urlPath), body,
+	)
+	if err != nil {
+		s.Fatal(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.SetBasicAuth(s.Cfg.HTTP.User, s.Cfg.HTTP.Password)
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		s.Fatal(err)
+	}
+	defer func() {
+		err := resp.Body.Close()
+		if err != nil {
+			s.Fatal(err)
+		}
+	}()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.Fatal(err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type syncMultiStatus struct {
+	Responses []struct {
+		Href   string `xml:"href"`
+		Status string `xml:"status"`
+	} `xml:"response"`
+	SyncToken string `xml:"sync-token"`
+}
+
+func syncCollection(ctx context.Context, t *testing.T, st *suite.Suite, calPath, token string) (*http.Response, *syncMultiStatus) {
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<D:sync-collection xmlns:D="DAV:">
+  <D:sync-token>%s</D:sync-token>
+  <D:sync-level>1</D:sync-level>
+  <D:prop><D:getetag/></D:prop>
+</D:sync-collection>`, token)
+
+	resp := st.Do(ctx, "REPORT", calPath, map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+	}, strings.NewReader(body))
+	if resp.StatusCode != http.StatusMultiStatus {
+		return resp, nil
+	}
+
+	var ms syncMultiStatus
+	require.NoError(t, xml.NewDecoder(resp.Body).Decode(&ms))
+	require.NotEmpty(t, ms.SyncToken)
+	return resp, &ms
+}
+
+func TestSyncCollection_Changes(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+
+	_, initial := syncCollection(ctx, t, st, testCalPath, "")
+	require.NotNil(t, initial)
+
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	_, added := syncCollection(ctx, t, st, testCalPath, initial.SyncToken)
+	require.NotNil(t, added)
+	require.Len(t, added.Responses, 1)
+	assert.Equal(t, objPath, added.Responses[0].Href)
+	assert.NotEqual(t, initial.SyncToken, added.SyncToken)
+
+	require.NoError(t, st.Client.RemoveAll(ctx, objPath))
+
+	_, removed := syncCollection(ctx, t, st, testCalPath, added.SyncToken)
+	require.NotNil(t, removed)
+	require.Len(t, removed.Responses, 1)
+	assert.Equal(t, objPath, removed.Responses[0].Href)
+	assert.Contains(t, removed.Responses[0].Status, "404")
+
+	_, unchanged := syncCollection(ctx, t, st, testCalPath, removed.SyncToken)
+	require.NotNil(t, unchanged)
+	assert.Empty(t, unchanged.Responses)
+	assert.Equal(t, removed.SyncToken, unchanged.SyncToken)
+}
+
+func TestSyncCollection_InvalidToken(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	for _, token := range []string{"bogus", "http://dav-go/ns/sync/0/1", "http://dav-go/ns/sync/1/999999999"} {
+		resp, _ := syncCollection(ctx, t, st, testCalPath, token)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, token)
+	}
+}
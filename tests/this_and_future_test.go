@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/emersion/go-ical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expandSeptember returns the instances of the calendar objects overlapping
+// the first weeks of September 2024.
+func expandSeptember(ctx context.Context, t *testing.T, st *suite.Suite, calPath string) string {
+	t.Helper()
+	resp := st.Do(ctx, "REPORT", calPath, map[string]string{
+		"Content-Type": "application/xml; charset=utf-8",
+		"Depth":        "1",
+	}, strings.NewReader(strings.NewReplacer(
+		"20240601T000000Z", "20240901T000000Z",
+		"20240701T000000Z", "20240920T000000Z",
+	).Replace(expandQuery)))
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+// putSplit puts the calendar object of the test case and returns the master
+// left in it and the first event of the series split off at the split time.
+func putSplit(ctx context.Context, t *testing.T, st *suite.Suite, calPath, split string) (*ical.Component, *ical.Component) {
+	t.Helper()
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(calPath, uid+suite.IcsExt)
+	put, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+	// The object is not stored as it was put
+	assert.Empty(t, put.ETag)
+
+	obj, err := st.Client.GetCalendarObject(ctx, objPath)
+	require.NoError(t, err)
+	require.Len(t, obj.Data.Events(), 1)
+
+	seriesUID := uid + "-" + split
+	series, err := st.Client.GetCalendarObject(ctx, path.Join(calPath, seriesUID+suite.IcsExt))
+	require.NoError(t, err)
+	require.Len(t, series.Data.Events(), 1)
+	event := series.Data.Events()[0]
+	gotUID, err := event.Props.Text(ical.PropUID)
+	require.NoError(t, err)
+	assert.Equal(t, seriesUID, gotUID)
+	assert.Nil(t, event.Props.Get(ical.PropRecurrenceID))
+
+	return obj.Data.Events()[0].Component, event.Component
+}
+
+func TestThisAndFuture_Split(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	master, event := putSplit(ctx, t, st, testCalPath, "20240904T090000Z")
+
+	// The master ends before the override, with its share of the COUNT
+	rule, err := master.Props.Text(ical.PropRecurrenceRule)
+	require.NoError(t, err)
+	assert.Contains(t, rule, "COUNT=2")
+	assert.Nil(t, master.Props.Get(ical.PropRecurrenceID))
+
+	// The override starts a new series with the rest of the instances
+	summary, err := event.Props.Text(ical.PropSummary)
+	require.NoError(t, err)
+	assert.Equal(t, "standup moved", summary)
+	rule, err = event.Props.Text(ical.PropRecurrenceRule)
+	require.NoError(t, err)
+	assert.Contains(t, rule, "COUNT=3")
+
+	// Only the recurrence of the new series is marked as split off
+	for uid, want := range map[string]bool{
+		"0b6f3c2e-8d4a-4e71-9a5c-3f1d2e8b7c60":                  false,
+		"0b6f3c2e-8d4a-4e71-9a5c-3f1d2e8b7c60-20240904T090000Z": true,
+	} {
+		var thisAndFuture bool
+		err := st.Pg.Pool.QueryRow(ctx, `
+			SELECT r.this_and_future = B'1'
+			FROM caldav.recurrence r
+				JOIN caldav.event_component e ON e.id = r.event_component_id
+				JOIN caldav.calendar_file c ON c.uid = e.calendar_file_uid
+			WHERE c.ical_uid = $1
+		`, uid).Scan(&thisAndFuture)
+		require.NoError(t, err)
+		assert.Equal(t, want, thisAndFuture, uid)
+	}
+
+	data := expandSeptember(ctx, t, st, testCalPath)
+	assert.Equal(t, 5, strings.Count(data, "RECURRENCE-ID:"))
+	assert.Contains(t, data, "DTSTART:20240903T090000Z")
+	assert.NotContains(t, data, "DTSTART:20240904T090000Z")
+	assert.Contains(t, data, "DTSTART:20240904T120000Z")
+	assert.Contains(t, data, "DTSTART:20240906T120000Z")
+	assert.NotContains(t, data, "DTSTART:20240907T")
+
+	// Putting the object again with its override updates the series split off
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+	code, _ := putRaw(ctx, t, st, path.Join(testCalPath, uid+suite.IcsExt), ical.MIMEType, encodeCalendar(t, calIn))
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, 5, strings.Count(expandSeptember(ctx, t, st, testCalPath), "RECURRENCE-ID:"))
+	obj, err := st.Client.GetCalendarObject(ctx, path.Join(testCalPath, uid+suite.IcsExt))
+	require.NoError(t, err)
+	rule, err = obj.Data.Events()[0].Props.Text(ical.PropRecurrenceRule)
+	require.NoError(t, err)
+	assert.Contains(t, rule, "COUNT=2")
+}
+
+func TestThisAndFuture_MovedSplit(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	putSplit(ctx, t, st, testCalPath, "20240904T090000Z")
+
+	// The override is moved a day later and the object put again
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+	for _, event := range calIn.Events() {
+		if event.Props.Get(ical.PropRecurrenceID) == nil {
+			continue
+		}
+		event.Props.Get(ical.PropRecurrenceID).Value = "20240905T090000Z"
+		event.Props.Get(ical.PropDateTimeStart).Value = "20240905T120000Z"
+		event.Props.Get(ical.PropDateTimeEnd).Value = "20240905T130000Z"
+	}
+	code, _ := putRaw(ctx, t, st, path.Join(testCalPath, uid+suite.IcsExt), ical.MIMEType, encodeCalendar(t, calIn))
+	require.Equal(t, http.StatusCreated, code)
+
+	// The series split off before is replaced by the new one
+	resp := st.Do(ctx, http.MethodGet, path.Join(testCalPath, uid+"-20240904T090000Z"+suite.IcsExt), nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = st.Do(ctx, http.MethodGet, path.Join(testCalPath, uid+"-20240905T090000Z"+suite.IcsExt), nil, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	data := expandSeptember(ctx, t, st, testCalPath)
+	assert.Equal(t, 5, strings.Count(data, "RECURRENCE-ID:"))
+	assert.Contains(t, data, "DTSTART:20240904T090000Z")
+	assert.NotContains(t, data, "DTSTART:20240904T120000Z")
+	assert.Contains(t, data, "DTSTART:20240905T120000Z")
+	assert.Contains(t, data, "DTSTART:20240906T120000Z")
+}
+
+func TestThisAndFuture_OwnRule(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	master, event := putSplit(ctx, t, st, testCalPath, "20240904T090000Z")
+
+	rule, err := master.Props.Text(ical.PropRecurrenceRule)
+	require.NoError(t, err)
+	assert.Contains(t, rule, "COUNT=2")
+
+	// The rule of the override is kept for the new series
+	rule, err = event.Props.Text(ical.PropRecurrenceRule)
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=WEEKLY;COUNT=2", rule)
+
+	data := expandSeptember(ctx, t, st, testCalPath)
+	assert.Equal(t, 4, strings.Count(data, "RECURRENCE-ID:"))
+	assert.Contains(t, data, "DTSTART:20240902T090000Z")
+	assert.Contains(t, data, "DTSTART:20240903T090000Z")
+	assert.Contains(t, data, "DTSTART:20240904T120000Z")
+	assert.NotContains(t, data, "DTSTART:20240905T")
+	assert.Contains(t, data, "DTSTART:20240911T120000Z")
+}
+
+func TestThisAndFuture_PastCount(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	master, event := putSplit(ctx, t, st, testCalPath, "20240906T090000Z")
+
+	// Every instance of the master comes before the split
+	rule, err := master.Props.Text(ical.PropRecurrenceRule)
+	require.NoError(t, err)
+	assert.Contains(t, rule, "COUNT=3")
+	assert.Nil(t, event.Props.Get(ical.PropRecurrenceRule))
+
+	data := expandSeptember(ctx, t, st, testCalPath)
+	assert.Contains(t, data, "DTSTART:20240904T090000Z")
+	assert.NotContains(t, data, "DTSTART:20240905T")
+	assert.Contains(t, data, "DTSTART:20240906T120000Z")
+	assert.NotContains(t, data, "DTSTART:20240907T")
+}
+
+func TestThisAndFuture_EarlierUntil(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	master, event := putSplit(ctx, t, st, testCalPath, "20240906T090000Z")
+
+	// The master is not extended up to the split
+	rule, err := master.Props.Text(ical.PropRecurrenceRule)
+	require.NoError(t, err)
+	assert.Contains(t, rule, "UNTIL=20240903T090000Z")
+	assert.Nil(t, event.Props.Get(ical.PropRecurrenceRule))
+
+	data := expandSeptember(ctx, t, st, testCalPath)
+	assert.Contains(t, data, "DTSTART:20240903T090000Z")
+	assert.NotContains(t, data, "DTSTART:20240904T")
+	assert.NotContains(t, data, "DTSTART:20240905T")
+	assert.Contains(t, data, "DTSTART:20240906T120000Z")
+}
+
+func TestThisAndFuture_FreeBusy(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+	calIn, uid := suite.GetCalendarObjectFromFile(t, suite.InputExt)
+
+	objPath := path.Join(testCalPath, uid+suite.IcsExt)
+	_, err := st.Client.PutCalendarObject(ctx, objPath, calIn)
+	require.NoError(t, err)
+
+	fb := freeBusyQuery(ctx, t, st, testCalPath, "20240902T000000Z", "20240910T000000Z")
+	assert.Contains(t, fb, "FREEBUSY:20240902T090000Z/20240902T100000Z")
+	assert.Contains(t, fb, "FREEBUSY:20240903T090000Z/20240903T100000Z")
+	assert.NotContains(t, fb, "20240904T090000Z")
+	assert.Contains(t, fb, "FREEBUSY:20240904T120000Z/20240904T130000Z")
+	assert.Contains(t, fb, "FREEBUSY:20240906T120000Z/20240906T130000Z")
+	assert.NotContains(t, fb, "20240907T")
+}
@@ -0,0 +1,12 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+)
+
+func TestTimezone_MoscowWeekly(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"path"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Raimguzhinov/dav-go/internal/caldav/db"
+	"github.com/Raimguzhinov/dav-go/pkg/logger"
+	"github.com/Raimguzhinov/dav-go/tests/suite"
+	"github.com/emersion/go-ical"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTask(uid, summary string, relations ...ical.Prop) *ical.Calendar {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropProductID, "-//xyz Protei-Lab//Raimguzhinov DAV-GO V1.0.0//EN")
+	cal.Props.SetText(ical.PropVersion, "2.0")
+
+	todo := ical.NewComponent(ical.CompToDo)
+	todo.Props.SetText(ical.PropUID, uid)
+	todo.Props.SetText(ical.PropSummary, summary)
+	todo.Props.SetDateTime(ical.PropDateTimeStamp, time.Date(2024, 7, 8, 9, 0, 0, 0, time.UTC))
+	todo.Props.SetDateTime(ical.PropDue, time.Date(2024, 7, 12, 18, 0, 0, 0, time.UTC))
+	for _, rel := range relations {
+		todo.Props.Add(&rel)
+	}
+	cal.Children = append(cal.Children, todo)
+	return cal
+}
+
+func relatedTo(uid, relType string) ical.Prop {
+	prop := ical.NewProp(ical.PropRelatedTo)
+	prop.Value = uid
+	if relType != "" {
+		prop.Params.Set(ical.ParamRelationshipType, relType)
+	}
+	return *prop
+}
+
+func TestTodo_DueCompletedRelated(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	suite.CompareContentsByTestName(ctx, t, st)
+}
+
+func TestTodo_TaskTree(t *testing.T) {
+	ctx, st := suite.New(t, true)
+	testCalPath := suite.GetCalendars(ctx, t, st)
+
+	release := uuid.NewString()
+	notes := uuid.NewString()
+	changelog := uuid.NewString()
+	announce := uuid.NewString()
+	orphan := uuid.NewString()
+
+	tasks := []*ical.Calendar{
+		newTask(release, "release", relatedTo(announce, "CHILD")),
+		newTask(notes, "release notes", relatedTo(release, "")),
+		newTask(changelog, "changelog", relatedTo(notes, "PARENT")),
+		newTask(announce, "announce"),
+		newTask(orphan, "orphan", relatedTo(uuid.NewString(), "")),
+	}
+	for _, cal := range tasks {
+		uid, err := cal.Children[0].Props.Text(ical.PropUID)
+		require.NoError(t, err)
+		_, err = st.Client.PutCalendarObject(ctx, path.Join(testCalPath, uid+suite.IcsExt), cal)
+		require.NoError(t, err)
+	}
+
+	folderID, err := strconv.Atoi(path.Base(path.Clean(testCalPath)))
+	require.NoError(t, err)
+	repo := db.NewRepository(st.Pg, logger.New("error", "prod"))
+
+	roots, err := repo.FindTaskTree(ctx, st.Cfg.HTTP.User, folderID)
+	require.NoError(t, err)
+	require.Len(t, roots, 2)
+
+	assert.Equal(t, release, roots[0].UID)
+	assert.True(t, roots[0].Due.Valid)
+	require.Len(t, roots[0].Subtasks, 2)
+	assert.Equal(t, notes, roots[0].Subtasks[0].UID)
+	assert.Equal(t, announce, roots[0].Subtasks[1].UID)
+	require.Len(t, roots[0].Subtasks[0].Subtasks, 1)
+	assert.Equal(t, changelog, roots[0].Subtasks[0].Subtasks[0].UID)
+
+	assert.Equal(t, orphan, roots[1].UID)
+	assert.Empty(t, roots[1].Subtasks)
+}